@@ -0,0 +1,248 @@
+package mgrep
+
+import (
+	"io"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+// Public types are converted to internal ones and back at the boundary of the package,
+// so internal packages can change without breaking the API
+
+func publicMatches(matches []base.Match) []Match {
+	if matches == nil {
+		return nil
+	}
+	converted := make([]Match, len(matches))
+	for i, match := range matches {
+		converted[i] = Match(match)
+	}
+	return converted
+}
+
+func internalMatches(matches []Match) []base.Match {
+	if matches == nil {
+		return nil
+	}
+	converted := make([]base.Match, len(matches))
+	for i, match := range matches {
+		converted[i] = base.Match(match)
+	}
+	return converted
+}
+
+func publicResult(result base.SearchResult) Result {
+	return Result{
+		Path:          result.Path,
+		LineNumber:    result.LineNumber,
+		EndLineNumber: result.EndLineNumber,
+		Line:          result.Line,
+		Matches:       publicMatches(result.Matches),
+		IsContext:     result.IsContext,
+		IsBinary:      result.IsBinary,
+		Truncated:     result.Truncated,
+		LineOffset:    result.LineOffset,
+		ColumnOffset:  result.ColumnOffset,
+	}
+}
+
+func internalResult(result Result) base.SearchResult {
+	return base.SearchResult{
+		Path:          result.Path,
+		LineNumber:    result.LineNumber,
+		EndLineNumber: result.EndLineNumber,
+		Line:          result.Line,
+		Matches:       internalMatches(result.Matches),
+		IsContext:     result.IsContext,
+		IsBinary:      result.IsBinary,
+		Truncated:     result.Truncated,
+		LineOffset:    result.LineOffset,
+		ColumnOffset:  result.ColumnOffset,
+	}
+}
+
+// Converts a warning to *Warning. Other errors are returned as is
+func publicError(err error) error {
+	if warning, ok := err.(*base.Warning); ok {
+		return (*Warning)(warning)
+	}
+	return err
+}
+
+func publicSummary(summary base.SearchSummary) Summary {
+	var warnings []*Warning
+	for _, warning := range summary.Warnings {
+		warnings = append(warnings, (*Warning)(warning))
+	}
+	return Summary{Matches: summary.Matches, Errors: summary.Errors, Err: summary.Err, Warnings: warnings}
+}
+
+// Reader of an internal package
+type publicReader struct {
+	reader base.Reader
+}
+
+func (r *publicReader) OpenFile(fileEntry DirEntry) (io.ReadCloser, error) {
+	return r.reader.OpenFile(base.DirEntry(fileEntry))
+}
+
+func (r *publicReader) ReadDir(dirEntry DirEntry) (DirIterator, error) {
+	iter, err := r.reader.ReadDir(base.DirEntry(dirEntry))
+	if iter == nil {
+		return nil, err
+	}
+	return &publicIterator{iter}, err
+}
+
+func (r *publicReader) ReadRootEntry(name string, depth int) (DirEntry, error) {
+	entry, err := r.reader.ReadRootEntry(name, depth)
+	return DirEntry(entry), err
+}
+
+type publicIterator struct {
+	iter base.Iterator[base.DirEntry]
+}
+
+func (i *publicIterator) Next() bool {
+	return i.iter.Next()
+}
+
+func (i *publicIterator) Value() DirEntry {
+	return DirEntry(i.iter.Value())
+}
+
+func (i *publicIterator) Err() error {
+	return i.iter.Err()
+}
+
+func (i *publicIterator) Close() error {
+	if closer, ok := i.iter.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Reader that is passed to internal packages. Readers of internal packages are unwrapped
+func adaptReader(reader Reader) base.Reader {
+	if public, ok := reader.(*publicReader); ok {
+		return public.reader
+	}
+	return &readerAdapter{reader}
+}
+
+type readerAdapter struct {
+	reader Reader
+}
+
+func (r *readerAdapter) OpenFile(fileEntry base.DirEntry) (io.ReadCloser, error) {
+	return r.reader.OpenFile(DirEntry(fileEntry))
+}
+
+func (r *readerAdapter) ReadDir(dirEntry base.DirEntry) (base.Iterator[base.DirEntry], error) {
+	iter, err := r.reader.ReadDir(DirEntry(dirEntry))
+	if iter == nil {
+		return nil, err
+	}
+	return &iteratorAdapter{iter}, err
+}
+
+func (r *readerAdapter) ReadRootEntry(name string, depth int) (base.DirEntry, error) {
+	entry, err := r.reader.ReadRootEntry(name, depth)
+	return base.DirEntry(entry), err
+}
+
+type iteratorAdapter struct {
+	iter DirIterator
+}
+
+func (i *iteratorAdapter) Next() bool {
+	return i.iter.Next()
+}
+
+func (i *iteratorAdapter) Value() base.DirEntry {
+	return base.DirEntry(i.iter.Value())
+}
+
+func (i *iteratorAdapter) Err() error {
+	return i.iter.Err()
+}
+
+func (i *iteratorAdapter) Close() error {
+	if closer, ok := i.iter.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Sink of an internal package
+type publicSink struct {
+	sink base.Sink
+}
+
+func (s *publicSink) HandleResult(result Result) {
+	s.sink.HandleResult(internalResult(result))
+}
+
+func (s *publicSink) HandleFileEnd(path string, matches int) {
+	s.sink.HandleFileEnd(path, matches)
+}
+
+// Writes the end of the output like a summary message of JSON sink
+func (s *publicSink) Close() error {
+	if closer, ok := s.sink.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Sink that is passed to internal packages. Sinks of internal packages are unwrapped
+func adaptSink(sink Sink) base.Sink {
+	if public, ok := sink.(*publicSink); ok {
+		return public.sink
+	}
+	return &sinkAdapter{sink}
+}
+
+type sinkAdapter struct {
+	sink Sink
+}
+
+func (s *sinkAdapter) HandleResult(result base.SearchResult) {
+	s.sink.HandleResult(publicResult(result))
+}
+
+func (s *sinkAdapter) HandleFileEnd(path string, matches int) {
+	s.sink.HandleFileEnd(path, matches)
+}
+
+type filterAdapter struct {
+	filter Filter
+}
+
+func (f *filterAdapter) SkipDirEntry(dirEntry base.DirEntry) bool {
+	return f.filter.SkipDirEntry(DirEntry(dirEntry))
+}
+
+func (f *filterAdapter) SkipFileEntry(fileEntry base.DirEntry) bool {
+	return f.filter.SkipFileEntry(DirEntry(fileEntry))
+}
+
+func (f *filterAdapter) SkipSearchResult(searchResult base.SearchResult) bool {
+	return f.filter.SkipSearchResult(publicResult(searchResult))
+}
+
+type matcherAdapter struct {
+	matcher Matcher
+}
+
+func (m *matcherAdapter) FindAll(b []byte, n int) []base.Match {
+	return internalMatches(m.matcher.FindAll(b, n))
+}
+
+func (m *matcherAdapter) MultiLine() bool {
+	return m.matcher.MultiLine()
+}
+
+func (m *matcherAdapter) Literals() []string {
+	return m.matcher.Literals()
+}
@@ -3,28 +3,108 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"runtime"
+	"strings"
+
+	"github.com/pi-kei/mgrep/internal/base"
+	"github.com/pi-kei/mgrep/internal/filter"
+	"github.com/pi-kei/mgrep/internal/glob"
+	"github.com/pi-kei/mgrep/internal/matcher"
+	"github.com/pi-kei/mgrep/internal/scanner"
 )
 
 // Search options
 type searchOptions struct {
-	maxSize       int64          // max size of file to scan in bytes
-	maxLength     int            // max length of a line to scan
-	include       *regexp.Regexp // include files that have matching path
-	exclude       *regexp.Regexp // exclude files that have matching path
-	matchCase     bool           // case-sensitivity
-	concurrency   int            // number of goroutines to spawn
-	bufferSize    int            // size of buffers of channels
-	maxDepth      int            // max recursion depth
-	noSkip        bool           // do not skip anything
-	profile       string         // set to cpu, heap, block, mutex or trace
+	maxSize        int64              // max size of file to scan in bytes
+	maxLength      int                // max length of a line to print
+	include        *regexp.Regexp     // include files that have matching path
+	exclude        *regexp.Regexp     // exclude files that have matching path
+	matchCase      bool               // case-sensitivity
+	concurrency    int                // number of goroutines to spawn
+	bufferSize     int                // size of buffers of channels
+	maxDepth       int                // max recursion depth
+	noSkip         bool               // do not skip anything
+	profile        string             // set to cpu, heap, block, mutex or trace
+	onlyMatching   bool               // print only matched parts of lines
+	before         int                // number of context lines before a match
+	after          int                // number of context lines after a match
+	ordered        bool               // keep results in walk order in concurrency mode
+	json           bool               // print results as JSON Lines
+	noIgnore       bool               // do not read ignore files
+	binaryMode     scanner.BinaryMode // how to handle binary files
+	quiet          bool               // print nothing and stop on the first match
+	fixed          bool               // search pattern is a list of literal strings separated by new lines
+	invert         bool               // search lines that do not match
+	count          bool               // print number of matching lines per file
+	filesWith      bool               // print only paths of files that have matches
+	filesWithout   bool               // print only paths of files that have no matches
+	firstMatch     bool               // stop scanning a file after its first matching line
+	replace        *string            // replacement template of matches. Nil when not replacing
+	write          bool               // rewrite files instead of printing a diff when replacing
+	multiLine      bool               // search matches that span several lines
+	searchZip      bool               // search inside of compressed files
+	archives       bool               // search inside of archives as directories
+	archiveNesting int                // max number of archives a file can be in
+	maxArchiveSize int64              // max size of an archive that is read to memory in bytes
+	follow         bool               // follow symbolic links
+	devices        bool               // read devices, named pipes and sockets instead of skipping them
+	noMessages     bool               // do not print errors and warnings about dirs and files
+	unsorted       bool               // read directories in batches in the order of the file system
+	types          base.Filter        // keeps files of selected types and skips files of excluded types. Nil when no types are set
+	globs          *glob.Set          // include and exclude globs of paths relative to search dir. Nil when no globs are set
+}
+
+// Flag that can be repeated to collect several values
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// Flag that adds globs to a shared set, so globs of several flags keep their order
+type globFlag struct {
+	set        *glob.Set
+	ignoreCase bool
+}
+
+func (g globFlag) String() string {
+	return ""
+}
+
+func (g globFlag) Set(value string) error {
+	return g.set.Add(value, g.ignoreCase)
+}
+
+// Reads patterns from a file, one per line. Reads standard input if name is -
+func readPatternsFile(name string) ([]string, error) {
+	var content []byte
+	var err error
+	if name == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimSuffix(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	if len(text) == 0 {
+		return nil, nil
+	}
+	return strings.Split(text, "\n"), nil
 }
 
-func parseArguments() (searchDir string, searchRegexp *regexp.Regexp, options searchOptions) {
+func parseArguments() (searchDir string, searchMatcher base.Matcher, options searchOptions) {
 	maxSizeFlag := flag.Int64("max-size", 1024 * 1024, "Max file size in bytes")
-	maxLengthFlag := flag.Int("max-length", 1024, "Max line length")
+	maxLengthFlag := flag.Int("max-length", 1024, "Max line length. Longer lines are cut around the first match and marked as truncated")
 	includeFlag := flag.String("include", "", "Regexp of paths to include")
 	excludeFlag := flag.String("exclude", "", "Regexp of paths to exclude")
 	matchCaseFlag := flag.Bool("match-case", false, "Match case")
@@ -33,18 +113,111 @@ func parseArguments() (searchDir string, searchRegexp *regexp.Regexp, options se
 	bufferSizeFlag := flag.Int("buf-size", 1024, "Size of the buffers")
 	maxDepthFlag := flag.Int("max-depth", 100, "Max recursion depth")
 	noSkipFlag := flag.Bool("no-skip", false, "Do not skip anything")
+	noIgnoreFlag := flag.Bool("no-ignore", false, "Do not skip paths listed in .gitignore, .ignore and .mgrepignore files")
 	profileFlag := flag.String("prof", "", "Run profiling. Set to cpu, heap, block, mutex or trace")
+	onlyMatchingFlag := flag.Bool("only-matching", false, "Print only matched parts of lines, each on a separate line")
+	binaryFlag := flag.String("binary", "matches", "How to handle binary files. Set to matches to report only whether they match, skip to not scan them or text to scan them as text")
+	var patternsFlag, patternsFileFlag stringsFlag
+	flag.Var(&patternsFlag, "e", "Same as regexp")
+	flag.Var(&patternsFlag, "regexp", "Search pattern. Can be repeated to search several patterns at once. Search string argument is not expected then")
+	flag.Var(&patternsFileFlag, "f", "Same as file")
+	flag.Var(&patternsFileFlag, "file", "File with search patterns, one per line. Can be repeated. Search string argument is not expected then")
+	var invertFlag, countFlag, filesWithFlag, filesWithoutFlag bool
+	flag.BoolVar(&invertFlag, "v", false, "Same as invert-match")
+	flag.BoolVar(&invertFlag, "invert-match", false, "Search lines that do not match")
+	flag.BoolVar(&countFlag, "c", false, "Same as count")
+	flag.BoolVar(&countFlag, "count", false, "Print only number of matching lines of each scanned file")
+	flag.BoolVar(&filesWithFlag, "l", false, "Same as files-with-matches")
+	flag.BoolVar(&filesWithFlag, "files-with-matches", false, "Print only paths of files that have matches")
+	flag.BoolVar(&filesWithoutFlag, "L", false, "Same as files-without-match")
+	flag.BoolVar(&filesWithoutFlag, "files-without-match", false, "Print only paths of files that have no matches")
+	replaceFlag := flag.String("replace", "", "Replace matches with a template where $1, ${1} and ${name} are expanded to capture groups. Prints a unified diff of changes")
+	writeFlag := flag.Bool("write", false, "Rewrite files instead of printing a diff when replacing")
+	var multiLineFlag bool
+	flag.BoolVar(&multiLineFlag, "U", false, "Same as multiline")
+	flag.BoolVar(&multiLineFlag, "multiline", false, "Search matches that span several lines. Each file is read as a whole then. Use \\n to match a new line, ^ and $ match at line boundaries")
+	var searchZipFlag bool
+	flag.BoolVar(&searchZipFlag, "z", false, "Same as search-zip")
+	flag.BoolVar(&searchZipFlag, "search-zip", false, "Search inside of gzip, bzip2, zlib and flate compressed files. Max size applies to decompressed content")
+	archivesFlag := flag.Bool("archives", false, "Search inside of zip, jar, war, ear, tar, tar.gz and tar.bz2 archives as directories. Files in them have paths like release.zip!/lib/config.yml")
+	archiveNestingFlag := flag.Int("archive-nesting", 1, "Max number of archives a file can be in. Archives that are nested deeper are searched as files")
+	maxArchiveSizeFlag := flag.Int64("max-archive-size", 64 * 1024 * 1024, "Max size in bytes of an archive that has to be read to memory. Those are compressed tar archives and archives inside of archives. Larger ones are skipped with a warning")
+	followFlag := flag.Bool("follow", false, "Follow symbolic links. Links to parent directories are skipped with a warning instead of being walked again")
+	unsortedFlag := flag.Bool("unsorted", false, "Read directories in batches in the order of the file system instead of sorting their entries. Uses less memory on huge directories, results are not sorted by path then")
+	devicesFlag := flag.String("devices", "skip", "How to handle devices, named pipes and sockets. Set to skip to not read them or read to read them. Reading them may block")
+	var fixedFlag bool
+	flag.BoolVar(&fixedFlag, "F", false, "Same as fixed-strings")
+	flag.BoolVar(&fixedFlag, "fixed-strings", false, "Treat search string as a list of literal strings separated by new lines")
+	var globs glob.Set
+	flag.Var(globFlag{&globs, false}, "g", "Same as glob")
+	flag.Var(globFlag{&globs, false}, "glob", "Include files that match a glob or exclude files and dirs if it starts with !. Globs have gitignore syntax and match paths relative to search dir. Glob with / at the start or in the middle is anchored to search dir, other globs match names at any level. Can be repeated, later globs take precedence")
+	flag.Var(globFlag{&globs, true}, "iglob", "Same as glob but case-insensitive")
+	var typeFlag, typeNotFlag, typeAddFlag stringsFlag
+	flag.Var(&typeFlag, "t", "Same as type")
+	flag.Var(&typeFlag, "type", "Search only files of a type like go, js or md. Can be repeated to search several types. See type-list")
+	flag.Var(&typeNotFlag, "T", "Same as type-not")
+	flag.Var(&typeNotFlag, "type-not", "Do not search files of a type. Can be repeated")
+	flag.Var(&typeAddFlag, "type-add", "Add a glob of base names to a type as name:glob, like foo:*.foo. Type is created if it does not exist. Can be repeated")
+	typeListFlag := flag.Bool("type-list", false, "Print known types with their globs and exit")
+	var noMessagesFlag bool
+	flag.BoolVar(&noMessagesFlag, "s", false, "Same as no-messages")
+	flag.BoolVar(&noMessagesFlag, "no-messages", false, "Do not print errors and warnings about dirs and files that failed to read. Exit code still tells about errors")
+	var quietFlag bool
+	flag.BoolVar(&quietFlag, "q", false, "Same as quiet")
+	flag.BoolVar(&quietFlag, "quiet", false, "Print nothing and stop on the first match. Exit code is 0 if match is found even if error occured")
+	jsonFlag := flag.Bool("json", false, "Print results as JSON Lines. Results are ordered then as with ordered")
+	orderedFlag := flag.Bool("ordered", false, "Print results in the same order as with no concurrency. Directories are walked by a single goroutine then")
+	var afterFlag, beforeFlag, contextFlag int
+	flag.IntVar(&afterFlag, "A", 0, "Same as after-context")
+	flag.IntVar(&afterFlag, "after-context", 0, "Print number of context lines after a match")
+	flag.IntVar(&beforeFlag, "B", 0, "Same as before-context")
+	flag.IntVar(&beforeFlag, "before-context", 0, "Print number of context lines before a match")
+	flag.IntVar(&contextFlag, "C", 0, "Same as context")
+	flag.IntVar(&contextFlag, "context", 0, "Print number of context lines before and after a match. Overridden by after-context and before-context. Results are ordered then as with ordered")
 
 	flag.Parse()
 
-	if flag.NArg() < 1 || flag.NArg() > 2 {
-		fmt.Println("Expecting search string and optionally search dir arguments")
-		os.Exit(1)
+	fileTypes := filter.DefaultFileTypes()
+	for _, definition := range typeAddFlag {
+		if err := fileTypes.Add(definition); err != nil {
+			fmt.Println("Invalid type-add", err)
+			os.Exit(2)
+		}
+	}
+
+	if *typeListFlag {
+		for _, name := range fileTypes.Names() {
+			fmt.Printf("%s: %s\n", name, strings.Join(fileTypes[name], ", "))
+		}
+		os.Exit(0)
+	}
+
+	searchPatterns := []string(patternsFlag)
+	for _, name := range patternsFileFlag {
+		filePatterns, err := readPatternsFile(name)
+		if err != nil {
+			fmt.Println("Invalid file", err)
+			os.Exit(2)
+		}
+		searchPatterns = append(searchPatterns, filePatterns...)
+	}
+
+	args := flag.Args()
+	if len(patternsFlag) == 0 && len(patternsFileFlag) == 0 {
+		if len(args) < 1 || len(args) > 2 {
+			fmt.Println("Expecting search string and optionally search dir arguments")
+			os.Exit(2)
+		}
+		searchPatterns = append(searchPatterns, args[0])
+		args = args[1:]
+	} else if len(args) > 1 {
+		fmt.Println("Expecting optionally search dir argument")
+		os.Exit(2)
 	}
 
 	searchDir = "."
-	if flag.NArg() == 2 {
-		searchDir = flag.Arg(1)
+	if len(args) == 1 {
+		searchDir = args[0]
 	}
 
 	options = searchOptions{
@@ -57,14 +230,46 @@ func parseArguments() (searchDir string, searchRegexp *regexp.Regexp, options se
 		bufferSize: *bufferSizeFlag,
 		maxDepth: *maxDepthFlag,
 		noSkip: *noSkipFlag,
+		noIgnore: *noIgnoreFlag,
 		profile: *profileFlag,
+		onlyMatching: *onlyMatchingFlag,
+		ordered: *orderedFlag,
+		json: *jsonFlag,
+		quiet: quietFlag,
+		noMessages: noMessagesFlag,
+		fixed: fixedFlag,
+		invert: invertFlag,
+		count: countFlag,
+		filesWith: filesWithFlag,
+		filesWithout: filesWithoutFlag,
+		write: *writeFlag,
+		multiLine: multiLineFlag,
+		searchZip: searchZipFlag,
+		archives: *archivesFlag,
+		archiveNesting: *archiveNestingFlag,
+		maxArchiveSize: *maxArchiveSizeFlag,
+		follow: *followFlag,
+		unsorted: *unsortedFlag,
+		before: contextFlag,
+		after: contextFlag,
 	}
 
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "replace":
+			options.replace = replaceFlag
+		case "A", "after-context":
+			options.after = afterFlag
+		case "B", "before-context":
+			options.before = beforeFlag
+		}
+	})
+
 	if len(*includeFlag) > 0 {
 		include, err := regexp.Compile(*includeFlag)
 		if err != nil {
 			fmt.Println("Invalid include", err)
-			os.Exit(1)
+			os.Exit(2)
 		}
 		options.include = include
 	}
@@ -73,26 +278,106 @@ func parseArguments() (searchDir string, searchRegexp *regexp.Regexp, options se
 		exclude, err := regexp.Compile(*excludeFlag)
 		if err != nil {
 			fmt.Println("Invalid exclude", err)
-			os.Exit(1)
+			os.Exit(2)
 		}
 		options.exclude = exclude
 	}
 
-	searchPattern := flag.Arg(0)
-	if !options.matchCase {
-		searchPattern = "(?i)" + searchPattern
+	switch *binaryFlag {
+	case "matches":
+		options.binaryMode = scanner.BinaryMatches
+	case "skip":
+		options.binaryMode = scanner.BinarySkip
+	case "text":
+		options.binaryMode = scanner.BinaryText
+	default:
+		fmt.Println("Invalid binary", *binaryFlag)
+		os.Exit(2)
 	}
-	
-	searchRegexp, err := regexp.Compile(searchPattern)
-	if err != nil {
-		fmt.Println("Invalid search pattern", err)
-		os.Exit(1)
+
+	if !globs.Empty() {
+		options.globs = &globs
+	}
+
+	if len(typeFlag) > 0 || len(typeNotFlag) > 0 {
+		types, err := filter.NewType(fileTypes, typeFlag, typeNotFlag)
+		if err != nil {
+			fmt.Println("Invalid type", err)
+			os.Exit(2)
+		}
+		options.types = types
+	}
+
+	switch *devicesFlag {
+	case "skip":
+	case "read":
+		options.devices = true
+	default:
+		fmt.Println("Invalid devices", *devicesFlag)
+		os.Exit(2)
+	}
+
+	// JSON messages and context groups of a file are written together, so results of files must not interleave
+	if options.json || options.before > 0 || options.after > 0 {
+		options.ordered = true
+	}
+
+	if options.write && options.replace == nil {
+		fmt.Println("Expecting replace with write")
+		os.Exit(2)
+	}
+
+	if options.searchZip && options.replace != nil {
+		fmt.Println("Expecting no search-zip with replace")
+		os.Exit(2)
+	}
+
+	if options.archives && options.replace != nil {
+		fmt.Println("Expecting no archives with replace")
+		os.Exit(2)
+	}
+
+	if len(searchPatterns) == 0 {
+		fmt.Println("Expecting at least one search pattern")
+		os.Exit(2)
+	}
+
+	if options.fixed {
+		var literals []string
+		for _, searchPattern := range searchPatterns {
+			literals = append(literals, strings.Split(searchPattern, "\n")...)
+		}
+		searchMatcher = matcher.NewFixed(literals, !options.matchCase)
+	} else {
+		for i := range searchPatterns {
+			if !options.matchCase {
+				searchPatterns[i] = "(?i)" + searchPatterns[i]
+			}
+			if options.multiLine {
+				searchPatterns[i] = "(?m)" + searchPatterns[i]
+			}
+		}
+
+		searchRegexp, err := matcher.CompileRegexps(searchPatterns)
+		if err != nil {
+			fmt.Println("Invalid search pattern", err)
+			os.Exit(2)
+		}
+		searchMatcher = searchRegexp
 	}
 
 	if options.maxSize < 1 {
 		options.maxSize = 1
 	}
 
+	if options.archiveNesting < 1 {
+		options.archiveNesting = 1
+	}
+
+	if options.maxArchiveSize < 1 {
+		options.maxArchiveSize = 1
+	}
+
 	if options.maxLength < 1 {
 		options.maxLength = 1
 	}
@@ -105,9 +390,25 @@ func parseArguments() (searchDir string, searchRegexp *regexp.Regexp, options se
 		options.bufferSize = 0
 	}
 
+	// Modes that print no lines need no context
+	noLines := options.onlyMatching || options.count || options.filesWith || options.filesWithout || options.replace != nil
+
+	if options.before < 0 || noLines {
+		options.before = 0
+	}
+
+	if options.after < 0 || noLines {
+		options.after = 0
+	}
+
+	// Whether a file has a match is known after the first one
+	if options.filesWith || options.filesWithout || options.quiet {
+		options.firstMatch = true
+	}
+
 	if options.maxDepth < 0 || *noSubdirsFlag {
 		options.maxDepth = 0
 	}
 
-	return searchDir, searchRegexp, options
+	return searchDir, searchMatcher, options
 }
\ No newline at end of file
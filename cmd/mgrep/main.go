@@ -2,27 +2,41 @@ package main
 
 import (
 	"context"
+	"errors"
+	"io"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
-	"unicode/utf8"
 
 	"github.com/pi-kei/mgrep/internal/base"
 	"github.com/pi-kei/mgrep/internal/filter"
+	"github.com/pi-kei/mgrep/internal/glob"
+	"github.com/pi-kei/mgrep/internal/matcher"
 	"github.com/pi-kei/mgrep/internal/reader"
 	"github.com/pi-kei/mgrep/internal/scanner"
 	"github.com/pi-kei/mgrep/internal/searcher"
 	"github.com/pi-kei/mgrep/internal/sink"
 )
 
+// Exit codes compatible with grep
+const (
+	exitMatch   = 0 // at least one match found
+	exitNoMatch = 1 // no matches found
+	exitError   = 2 // error occured
+)
+
 func main() {
-	searchDir, searchRegexp, options := parseArguments()
+	os.Exit(run())
+}
+
+func run() int {
+	searchDir, searchMatcher, options := parseArguments()
 
 	finalizeProfile, err := getProfile(options.profile)
 	if err != nil {
 		log.Println(err)
-		return
+		return exitError
 	}
 	if finalizeProfile != nil {
 		defer finalizeProfile()
@@ -30,23 +44,116 @@ func main() {
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var fileSystemOptions []reader.FileSystemOption
+	if options.follow {
+		fileSystemOptions = append(fileSystemOptions, reader.WithFollow())
+	}
+	if options.unsorted {
+		fileSystemOptions = append(fileSystemOptions, reader.WithUnsorted())
+	}
+	readerIns := reader.NewFileSystem(fileSystemOptions...)
+	if options.archives {
+		readerIns = reader.NewArchive(readerIns, reader.WithArchiveMaxNesting(options.archiveNesting), reader.WithArchiveMaxSize(options.maxArchiveSize))
+	}
+	sinkIns := buildSink(options, cancel, readerIns, searchMatcher)
+	searcherIns := buildSearcher(options, searchDir, readerIns, sinkIns)
+	summary := searcherIns.Search(ctx, searchDir, searchMatcher)
+	if closer, ok := sinkIns.(io.Closer); ok {
+		if err := closer.Close(); err != nil && summary.Err == nil {
+			summary.Err = err
+		}
+	}
+
+	if options.quiet && summary.Matches > 0 {
+		return exitMatch
+	}
+	if summary.Err != nil || failedToRead(summary.Warnings) {
+		return exitError
+	}
+	if summary.Matches > 0 {
+		return exitMatch
+	}
+	return exitNoMatch
+}
+
+// Checks if entries were skipped because they failed to read. They are errors for the exit code, the same way as in grep.
+// Links to parent directories are only skipped
+func failedToRead(warnings []*base.Warning) bool {
+	for _, warning := range warnings {
+		if !errors.Is(warning, reader.ErrLinkLoop) {
+			return true
+		}
+	}
+	return false
+}
 
-	searcherIns := buildSearcher(options)
-	searcherIns.Search(ctx, searchDir, searchRegexp)
+func buildSink(options searchOptions, cancel context.CancelFunc, readerIns base.Reader, searchMatcher base.Matcher) base.Sink {
+	if options.quiet {
+		return sink.NewQuiet(cancel)
+	}
+	if options.replace != nil {
+		replacer, ok := searchMatcher.(base.Replacer)
+		if !ok || options.fixed {
+			replacer = matcher.NewLiteral()
+		}
+		var replaceOptions []sink.ReplaceOption
+		if options.write {
+			replaceOptions = append(replaceOptions, sink.WithReplaceInPlace())
+		}
+		return sink.NewReplace(readerIns, replacer, *options.replace, os.Stdout, replaceOptions...)
+	}
+	if options.filesWith {
+		return sink.NewFiles(os.Stdout)
+	}
+	if options.filesWithout {
+		return sink.NewFiles(os.Stdout, sink.WithFilesSelected(sink.FilesWithoutMatches))
+	}
+	if options.count {
+		return sink.NewFiles(os.Stdout, sink.WithFilesFormat(sink.CountFormat), sink.WithFilesGetValues(sink.CountGetValues), sink.WithFilesSelected(sink.AllFiles))
+	}
+	if options.json {
+		return sink.NewJSON(os.Stdout)
+	}
+	if options.onlyMatching {
+		return sink.NewWriter(os.Stdout, sink.WithWriterFormat(sink.OnlyMatchingFormat), sink.WithWriterGetValues(sink.OnlyMatchingGetValues))
+	}
+	if options.before > 0 || options.after > 0 {
+		return sink.NewWriter(os.Stdout, sink.WithWriterGroupSeparator(sink.DefaultGroupSeparator))
+	}
+	return sink.NewWriter(os.Stdout)
 }
 
-func buildSearcher(options searchOptions) base.Searcher {
-	reader := reader.NewFileSystem()
+func buildSearcher(options searchOptions, searchDir string, readerIns base.Reader, sinkIns base.Sink) base.Searcher {
+	var specialOptions []filter.SpecialOption
+	if options.devices {
+		specialOptions = append(specialOptions, filter.WithSpecialDevices())
+	}
+	// Links that are left when following are broken, so they are reported
+	if options.follow {
+		specialOptions = append(specialOptions, filter.WithSpecialLinks())
+	}
+	// Special files are skipped even with no-skip, reading them may block forever
 	var filterIns base.Filter
 	if options.noSkip {
-		filterIns = filter.NewNoop()
+		filterIns = filter.NewSpecial(specialOptions...)
 	} else {
-		filterIns = filter.NewConfigurable(
+		configurable := filter.NewConfigurable(
 			func(dirEntry base.DirEntry) bool {
-				return dirEntry.Depth > options.maxDepth
+				if dirEntry.Depth > options.maxDepth {
+					return true
+				}
+				// Search dir is never excluded by globs
+				if options.globs != nil && dirEntry.Path != searchDir && !options.globs.Selected(glob.RelativePath(searchDir, dirEntry.Path), true) {
+					return true
+				}
+				return false
 			},
 			func(fileEntry base.DirEntry) bool {
-				if fileEntry.Size == 0 {
+				// Devices and pipes have no size
+				if fileEntry.Mode.IsRegular() && fileEntry.Size == 0 {
 					return true
 				}
 				if fileEntry.Size > options.maxSize {
@@ -58,20 +165,61 @@ func buildSearcher(options searchOptions) base.Searcher {
 				if options.exclude != nil && options.exclude.MatchString(fileEntry.Path) {
 					return true
 				}
+				if options.globs != nil && !options.globs.Selected(glob.RelativePath(searchDir, fileEntry.Path), false) {
+					return true
+				}
 				return false
 			},
 			func(searchResult base.SearchResult) bool {
-				return utf8.RuneCountInString(searchResult.Line) > options.maxLength
+				return false
 			},
 		)
+		filters := []base.Filter{filter.NewSpecial(specialOptions...), configurable}
+		if options.types != nil {
+			filters = append(filters, options.types)
+		}
+		if !options.noIgnore {
+			filters = append(filters, filter.NewIgnore(readerIns, filter.DefaultIgnoreFileNames...))
+		}
+		filterIns = filter.NewChain(filters...)
+	}
+	scannerOptions := []scanner.LineOption{scanner.WithContext(options.before, options.after), scanner.WithBinaryMode(options.binaryMode)}
+	// Replacing needs full lines
+	if options.replace == nil {
+		scannerOptions = append(scannerOptions, scanner.WithMaxLength(options.maxLength))
+	}
+	if options.invert {
+		scannerOptions = append(scannerOptions, scanner.WithInvert())
+	}
+	if options.multiLine {
+		scannerOptions = append(scannerOptions, scanner.WithMultiLine(options.maxSize))
+	}
+	// Only scanner reads decompressed content. Ignore files and replaced files are read as is
+	scannerReader := readerIns
+	if options.searchZip {
+		scannerReader = reader.NewDecompress(readerIns, reader.WithDecompressMaxSize(options.maxSize))
+	}
+	scanner := scanner.NewLine(scannerReader, scannerOptions...)
+	logger := log.Default()
+	if options.noMessages {
+		logger = log.New(io.Discard, "", 0)
 	}
-	scanner := scanner.NewLine(reader)
-	sink := sink.NewWriter(os.Stdout)
 	var searcherIns base.Searcher
 	if options.concurrency == 0 {
-		searcherIns = searcher.NewSerial(scanner, filterIns, sink, log.Default())
+		var serialOptions []searcher.SerialOption
+		if options.firstMatch {
+			serialOptions = append(serialOptions, searcher.WithSerialFirstMatch())
+		}
+		searcherIns = searcher.NewSerial(scanner, filterIns, sinkIns, logger, serialOptions...)
 	} else {
-		searcherIns = searcher.NewConcurrent(scanner, filterIns, sink, log.Default(), options.concurrency, options.bufferSize)
+		var concurrentOptions []searcher.ConcurrentOption
+		if options.firstMatch {
+			concurrentOptions = append(concurrentOptions, searcher.WithConcurrentFirstMatch())
+		}
+		if options.ordered {
+			concurrentOptions = append(concurrentOptions, searcher.WithOrdered())
+		}
+		searcherIns = searcher.NewConcurrent(scanner, filterIns, sinkIns, logger, options.concurrency, options.bufferSize, concurrentOptions...)
 	}
 	return searcherIns
 }
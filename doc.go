@@ -0,0 +1,30 @@
+// Package mgrep is the search engine of the mgrep command as a library.
+//
+// Search walks a directory and returns results as an iterator:
+//
+//	results, summary := mgrep.Search(ctx, ".", mgrep.Options{Patterns: []string{"TODO"}})
+//	for result, err := range results {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(result.Path, result.LineNumber, result.Line)
+//	}
+//	fmt.Println(summary.Matches)
+//
+// Run does the same but hands results to a Sink. Files are read by a Reader
+// that can be any io/fs.FS wrapped by NewFSReader, so embed.FS and fstest.MapFS can be searched too.
+// Additional Filters skip directories, files and results.
+//
+// # Compatibility
+//
+// Package follows semantic versioning of the module. Within a major version:
+//
+//   - exported functions, types and constants are not removed or changed incompatibly;
+//   - new fields may be added to Options, Result and Summary, so use keyed struct literals;
+//   - zero value of a new Options field keeps the previous behaviour;
+//   - methods are not added to Reader, Filter, Sink and Matcher interfaces,
+//     so implementations outside of this module keep compiling.
+//
+// Every type of the API is defined in this package and converted to types of packages under internal
+// when a search runs. Packages under internal are not covered and may change in any release.
+package mgrep
@@ -4,40 +4,58 @@ import (
 	"context"
 	"errors"
 	"io"
-	"regexp"
+	"io/fs"
+	"iter"
 	"time"
 )
 
 // Dir entry
 type DirEntry struct {
-	Path    string    // path to entry
-	Depth   int       // recursion depth
-	IsDir   bool      // whether the entry describes a directory
-	Size    int64     // size of a file in bytes
-	ModTime time.Time // modification time
+	Path    string      // path to entry
+	Depth   int         // recursion depth
+	IsDir   bool        // whether the entry describes a directory
+	Size    int64       // size of a file in bytes
+	ModTime time.Time   // modification time
+	Mode    fs.FileMode // type bits like fs.ModeNamedPipe or fs.ModeSymlink. Zero for regular files and directories
 }
 
-// Represents a single match
+// Represents a single match in a line
+type Match struct {
+	StartIndex int // start index of a match 0-based
+	EndIndex   int // end index (exclusive) of a match 0-based
+	Pattern    int // index of a pattern that matched 0-based when several patterns are searched
+}
+
+// Represents a line that has matches or several lines when a match spans them
 type SearchResult struct {
-	Path       string // path to file
-	LineNumber int    // line number 1-based
-	StartIndex int    // start index of a match 0-based
-	EndIndex   int    // end index (exclusive) of a match 0-based
-	Line       string // full line that has a match
+	Path          string  // path to file
+	LineNumber    int     // line number 1-based
+	EndLineNumber int     // number of the last line 1-based when the result spans several lines, zero otherwise
+	Line          string  // full line that has a match. Lines are separated by new lines when the result spans several of them
+	Matches       []Match // all non-overlapping matches in a line in order of appearance
+	IsContext     bool    // whether the line is a context line around a match. It has no matches then
+	IsBinary      bool    // whether the file is binary and has a match. It has no line and matches then
+	Truncated     bool    // whether the line is too long and Line has only a part of it around the first match
+	LineOffset    int     // byte offset of Line in the full line when it is truncated
+	ColumnOffset  int     // rune offset of Line in the full line when it is truncated
 }
 
 // Generic iterator
 type Iterator[T any] interface {
 	// Goes to next element.
-	// Returns false when there are no more elements or error occured.
+	// Returns false when there are no more elements or error occured that stops the iteration.
+	// Returns true when the next element failed to read but the rest can be read. Err() returns its error then.
 	// If false is returned then any additional call to Next() would do nothing
 	Next() bool
 	// Returns current value.
 	// Call after Next().
 	// If Next() was not called yet then Value() returns default value of T.
-	// If Next() returned false then Value() returns the same value as before
+	// If Next() returned false then Value() returns the same value as before.
+	// If current element failed to read then only a part of it is set, like a path of an entry
 	Value() T
-	// Returns last occured error or nil
+	// Returns error of current element after Next() returned true
+	// or error that stopped the iteration after Next() returned false.
+	// Returns nil if there is no error
 	Err() error
 }
 
@@ -48,7 +66,8 @@ type Reader interface {
 	OpenFile(fileEntry DirEntry) (io.ReadCloser, error)
 	// Reads child entries from specified entry.
 	// Entry must be a directory.
-	// Returns iterator and error. Iterator can generate valid entries even if error is not nil
+	// Returns iterator and error. Iterator can generate valid entries even if error is not nil.
+	// Iterator that holds resources implements io.Closer and must be closed when it is not read to the end
 	ReadDir(dirEntry DirEntry) (Iterator[DirEntry], error)
 	// Reads root entry from specified name.
 	// Entry can be a file or directory
@@ -68,6 +87,26 @@ type Filter interface {
 	SkipSearchResult(searchResult SearchResult) bool
 }
 
+// Finds matches in a text
+type Matcher interface {
+	// Finds successive non-overlapping matches in b.
+	// Returns at most n matches or all of them if n < 0, nil if there are no matches
+	FindAll(b []byte, n int) []Match
+	// Checks if a match can contain a new line character and span several lines
+	MultiLine() bool
+	// Returns literal strings that every match contains.
+	// Text that misses any of them has no matches so it can be skipped without running the matcher.
+	// Returns nil if there are no such literals or checking them would not be faster than matching
+	Literals() []string
+}
+
+// Replaces matches in a text
+type Replacer interface {
+	// Appends src to dst with every match replaced by a template expanded for that match.
+	// Matches must be found in src by the matcher that the replacer belongs to
+	Replace(dst []byte, template string, src []byte, matches []Match) []byte
+}
+
 var (
 	ErrSkipItem = errors.New("skip item")
 	ErrSkipAll  = errors.New("skip all")
@@ -76,21 +115,57 @@ var (
 // Scans for matches
 type Scanner interface {
 	// Scans a file and calls a callback on each match.
-	// Callback returns an error if occured. Error could be either SkipItem, or SkipAll, or any other error
-	ScanFile(fileEntry DirEntry, searchRegexp *regexp.Regexp, callback func(SearchResult) error) error
+	// Callback returns an error if occured. Error could be either SkipItem, or SkipAll, or any other error.
+	// SkipItem stops scanning the rest of the file.
+	// Returns SkipItem if the file is skipped without scanning, like a binary file when binary files are skipped
+	ScanFile(fileEntry DirEntry, matcher Matcher, callback func(SearchResult) error) error
 	// Scans directories starting at the specified root path and calls a callback on each found entry.
-	// Callback returns an error if occured. Error could be either SkipItem, or SkipAll, or any other error
-	ScanDirs(rootPath string, depth int, callback func(DirEntry) error) error
+	// Callback returns an error if occured. Error could be either SkipItem, or SkipAll, or any other error.
+	// Entries and directories below the root that failed to read are skipped and passed to warn, scanning goes on
+	ScanDirs(rootPath string, depth int, callback func(DirEntry) error, warn func(*Warning)) error
 }
 
 // Handles search results
 type Sink interface {
 	// Handles search result
 	HandleResult(result SearchResult)
+	// Handles the end of a scanned file. Called after all results of the file are handled.
+	// Matches is a number of handled results of the file that have matches.
+	// Not called for files that failed to scan or were skipped by a scanner or when search is cancelled
+	HandleFileEnd(path string, matches int)
+}
+
+// Entry that failed to read and was skipped while its siblings were still scanned
+type Warning struct {
+	Path string // path to entry
+	Err  error  // reason the entry was skipped
+}
+
+func (w *Warning) Error() string {
+	return w.Path + ": " + w.Err.Error()
+}
+
+func (w *Warning) Unwrap() error {
+	return w.Err
+}
+
+// Outcome of a search
+type SearchSummary struct {
+	Matches  int        // number of handled results that have matches, context lines are not counted
+	Errors   int        // number of errors occured while scanning dirs and files
+	Err      error      // first occured error or nil
+	Warnings []*Warning // entries that were skipped because they failed to read. Not counted as errors
 }
 
 // Performs search
 type Searcher interface {
-	// Starts search
-	Search(ctx context.Context, rootPath string, searchRegexp *regexp.Regexp)
+	// Starts search and waits until it ends.
+	// Errors do not stop the search, they are counted in returned summary
+	Search(ctx context.Context, rootPath string, matcher Matcher) SearchSummary
+	// Starts search and returns results as an iterator instead of passing them to a sink.
+	// Errors of dirs and files are yielded with an empty result and do not stop the search.
+	// Warnings are yielded the same way as *Warning errors.
+	// Breaking the loop cancels the search and waits until it ends.
+	// Returned summary is filled when the iteration ends
+	Results(ctx context.Context, rootPath string, matcher Matcher) (iter.Seq2[SearchResult, error], *SearchSummary)
 }
@@ -0,0 +1,42 @@
+package filter
+
+import (
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+type Chain struct {
+	filters []base.Filter
+}
+
+// Filter that skips when any of specified filters skips.
+// Filters are checked in order so later filters are not called once an earlier one skips
+func NewChain(filters ...base.Filter) base.Filter {
+	return &Chain{filters}
+}
+
+func (c *Chain) SkipDirEntry(dirEntry base.DirEntry) bool {
+	for _, filter := range c.filters {
+		if filter.SkipDirEntry(dirEntry) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Chain) SkipFileEntry(fileEntry base.DirEntry) bool {
+	for _, filter := range c.filters {
+		if filter.SkipFileEntry(fileEntry) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Chain) SkipSearchResult(searchResult base.SearchResult) bool {
+	for _, filter := range c.filters {
+		if filter.SkipSearchResult(searchResult) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+func TestChainFilter(t *testing.T) {
+	calledTimes := 0
+	skipNothing := NewConfigurable(
+		func(dirEntry base.DirEntry) bool { calledTimes++; return false },
+		func(fileEntry base.DirEntry) bool { calledTimes++; return false },
+		func(searchResult base.SearchResult) bool { calledTimes++; return false },
+	)
+	skipAll := NewConfigurable(
+		func(dirEntry base.DirEntry) bool { calledTimes++; return true },
+		func(fileEntry base.DirEntry) bool { calledTimes++; return true },
+		func(searchResult base.SearchResult) bool { calledTimes++; return true },
+	)
+
+	filter := NewChain(skipNothing, NewNoop())
+	if filter.SkipDirEntry(base.DirEntry{IsDir: true}) || filter.SkipFileEntry(base.DirEntry{}) || filter.SkipSearchResult(base.SearchResult{}) {
+		t.Error("Returned true")
+	}
+	if calledTimes != 3 {
+		t.Errorf("Called times %v", calledTimes)
+	}
+
+	calledTimes = 0
+	filter = NewChain(skipAll, skipNothing)
+	if !filter.SkipDirEntry(base.DirEntry{IsDir: true}) || !filter.SkipFileEntry(base.DirEntry{}) || !filter.SkipSearchResult(base.SearchResult{}) {
+		t.Error("Returned false")
+	}
+	if calledTimes != 3 {
+		t.Errorf("Called times %v", calledTimes)
+	}
+}
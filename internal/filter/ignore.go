@@ -0,0 +1,99 @@
+package filter
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pi-kei/mgrep/internal/base"
+	"github.com/pi-kei/mgrep/internal/glob"
+)
+
+// Default names of ignore files in order of increasing precedence
+var DefaultIgnoreFileNames = []string{".gitignore", ".ignore", ".mgrepignore"}
+
+// Rules of a single directory
+type ignoreDir struct {
+	parent *ignoreDir
+	path   string
+	rules  glob.Rules
+}
+
+type Ignore struct {
+	reader    base.Reader
+	fileNames []string
+	mu        sync.RWMutex
+	dirs      map[string]*ignoreDir // dirs that are not skipped
+}
+
+// Filter that skips entries matched by rules of ignore files.
+// Ignore files are read from each directory when it is checked by SkipDirEntry.
+// Rules of an ignore file apply to entries below its directory and take precedence over rules of parent directories.
+// Directories must be checked before their child entries. Thread-safe.
+func NewIgnore(reader base.Reader, fileNames ...string) base.Filter {
+	return &Ignore{reader: reader, fileNames: fileNames, dirs: make(map[string]*ignoreDir)}
+}
+
+func (i *Ignore) SkipDirEntry(dirEntry base.DirEntry) bool {
+	dirPath := filepath.Clean(dirEntry.Path)
+	i.mu.RLock()
+	_, loaded := i.dirs[dirPath]
+	parent := i.dirs[parentKey(dirPath)]
+	i.mu.RUnlock()
+	if loaded {
+		return false
+	}
+	if parent != nil && parent.path != dirPath && parent.ignored(dirPath, true) {
+		return true
+	}
+	dir := &ignoreDir{parent: parent, path: dirPath}
+	for _, fileName := range i.fileNames {
+		dir.rules = append(dir.rules, i.readRules(filepath.Join(dirPath, fileName))...)
+	}
+	i.mu.Lock()
+	i.dirs[dirPath] = dir
+	i.mu.Unlock()
+	return false
+}
+
+func (i *Ignore) SkipFileEntry(fileEntry base.DirEntry) bool {
+	filePath := filepath.Clean(fileEntry.Path)
+	i.mu.RLock()
+	parent := i.dirs[parentKey(filePath)]
+	i.mu.RUnlock()
+	return parent != nil && parent.ignored(filePath, false)
+}
+
+func (i *Ignore) SkipSearchResult(searchResult base.SearchResult) bool {
+	return false
+}
+
+// Returns key of the parent directory of a clean path.
+// Parent of a top member of an archive is the archive, like x.zip for x.zip!/a
+func parentKey(path string) string {
+	return strings.TrimSuffix(filepath.Dir(path), "!")
+}
+
+// Reads rules of an ignore file. Missing or unreadable files have no rules
+func (i *Ignore) readRules(path string) glob.Rules {
+	file, err := i.reader.OpenFile(base.DirEntry{Path: path})
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	rules, _ := glob.ParseRules(file, false)
+	return rules
+}
+
+// Checks rules of this directory and its parents starting from the nearest one
+func (d *ignoreDir) ignored(path string, isDir bool) bool {
+	for dir := d; dir != nil; dir = dir.parent {
+		if len(dir.rules) == 0 {
+			continue
+		}
+		if matched, negated := dir.rules.Match(glob.RelativePath(dir.path, path), isDir); matched {
+			return !negated
+		}
+	}
+	return false
+}
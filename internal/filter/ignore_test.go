@@ -0,0 +1,101 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pi-kei/mgrep/internal/base"
+	"github.com/pi-kei/mgrep/internal/reader"
+)
+
+func TestIgnoreFilter(t *testing.T) {
+	now := time.Now().UTC()
+	rootIgnore := "*.log\n/build/\nnode_modules/\n"
+	subIgnore := "!keep.log\n/local.txt\n"
+	extraIgnore := "secret.*\n"
+	content := "content"
+	testEntries := reader.MockEntries{
+		"aaa":                        {ModTime: now},
+		"aaa/.gitignore":             {ModTime: now, Content: &rootIgnore},
+		"aaa/.mgrepignore":           {ModTime: now, Content: &extraIgnore},
+		"aaa/a.log":                  {ModTime: now, Content: &content},
+		"aaa/a.txt":                  {ModTime: now, Content: &content},
+		"aaa/secret.txt":             {ModTime: now, Content: &content},
+		"aaa/build":                  {ModTime: now},
+		"aaa/node_modules":           {ModTime: now},
+		"aaa/sub":                    {ModTime: now},
+		"aaa/sub/.ignore":            {ModTime: now, Content: &subIgnore},
+		"aaa/sub/build":              {ModTime: now},
+		"aaa/sub/node_modules":       {ModTime: now},
+		"aaa/sub/keep.log":           {ModTime: now, Content: &content},
+		"aaa/sub/drop.log":           {ModTime: now, Content: &content},
+		"aaa/sub/local.txt":          {ModTime: now, Content: &content},
+		"aaa/sub/deeper":             {ModTime: now},
+		"aaa/sub/deeper/local.txt":   {ModTime: now, Content: &content},
+		"aaa/sub/deeper/secret.json": {ModTime: now, Content: &content},
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		skip  bool
+	}{
+		{"aaa", true, false},
+		{"aaa/.gitignore", false, false},
+		{"aaa/a.log", false, true},
+		{"aaa/a.txt", false, false},
+		{"aaa/secret.txt", false, true},
+		{"aaa/build", true, true},
+		{"aaa/node_modules", true, true},
+		{"aaa/sub", true, false},
+		{"aaa/sub/build", true, false},
+		{"aaa/sub/node_modules", true, true},
+		{"aaa/sub/keep.log", false, false},
+		{"aaa/sub/drop.log", false, true},
+		{"aaa/sub/local.txt", false, true},
+		{"aaa/sub/deeper", true, false},
+		{"aaa/sub/deeper/local.txt", false, false},
+		{"aaa/sub/deeper/secret.json", false, true},
+	}
+	// Root can be written in several ways, paths of its children are joined and clean
+	for _, root := range []string{"aaa", "aaa/", "./aaa"} {
+		filter := NewIgnore(reader.NewMockReader(testEntries), DefaultIgnoreFileNames...)
+		for _, test := range tests {
+			path := test.path
+			if path == "aaa" {
+				path = root
+			}
+			entry := base.DirEntry{Path: path, IsDir: test.isDir}
+			var skip bool
+			if test.isDir {
+				skip = filter.SkipDirEntry(entry)
+			} else {
+				skip = filter.SkipFileEntry(entry)
+			}
+			if skip != test.skip {
+				t.Errorf("Root %v: skip %v returned %v", root, path, skip)
+			}
+		}
+	}
+
+	filter := NewIgnore(reader.NewMockReader(testEntries), DefaultIgnoreFileNames...)
+	for _, path := range []string{"aaa", "aaa/sub"} {
+		filter.SkipDirEntry(base.DirEntry{Path: path, IsDir: true})
+	}
+
+	// Checking a directory again gives the same result
+	if filter.SkipDirEntry(base.DirEntry{Path: "aaa/sub", IsDir: true}) {
+		t.Error("Second check of aaa/sub returned true")
+	}
+	if filter.SkipSearchResult(base.SearchResult{Path: "aaa/a.log"}) {
+		t.Error("SkipSearchResult returned true")
+	}
+
+	// Members of an archive are below the archive
+	if filter.SkipDirEntry(base.DirEntry{Path: "aaa/x.zip", IsDir: true}) {
+		t.Error("Archive returned true")
+	}
+	if !filter.SkipFileEntry(base.DirEntry{Path: "aaa/x.zip!/a.log"}) || filter.SkipFileEntry(base.DirEntry{Path: "aaa/x.zip!/a.txt"}) {
+		t.Error("Members of archive are not checked by rules of its parents")
+	}
+}
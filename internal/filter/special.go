@@ -0,0 +1,58 @@
+package filter
+
+import (
+	"io/fs"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+type Special struct {
+	devices bool // whether devices, named pipes and sockets are read
+	links   bool // whether symbolic links are read
+}
+
+type SpecialOption func(*Special)
+
+// Makes filter keep devices, named pipes and sockets. Reading them may block
+func WithSpecialDevices() SpecialOption {
+	return func(s *Special) {
+		s.devices = true
+	}
+}
+
+// Makes filter keep symbolic links. Reader that follows links leaves only broken ones,
+// so they are reported as errors when opened
+func WithSpecialLinks() SpecialOption {
+	return func(s *Special) {
+		s.links = true
+	}
+}
+
+// Filter that skips files that are not regular, like devices, named pipes, sockets and symbolic links.
+// Root of a search is never a link, readers resolve it
+func NewSpecial(options ...SpecialOption) base.Filter {
+	special := Special{}
+	for _, option := range options {
+		option(&special)
+	}
+	return &special
+}
+
+func (s *Special) SkipDirEntry(dirEntry base.DirEntry) bool {
+	return false
+}
+
+func (s *Special) SkipFileEntry(fileEntry base.DirEntry) bool {
+	mode := fileEntry.Mode
+	if s.links {
+		mode &^= fs.ModeSymlink
+	}
+	if s.devices {
+		mode &^= fs.ModeDevice | fs.ModeCharDevice | fs.ModeNamedPipe | fs.ModeSocket
+	}
+	return mode.Type() != 0
+}
+
+func (s *Special) SkipSearchResult(searchResult base.SearchResult) bool {
+	return false
+}
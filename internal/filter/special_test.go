@@ -0,0 +1,55 @@
+package filter
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+func TestSpecialFilter_SkipDirEntry(t *testing.T) {
+	filter := NewSpecial()
+
+	skip := filter.SkipDirEntry(base.DirEntry{IsDir: true})
+	if skip {
+		t.Error("Returned true")
+	}
+}
+
+func TestSpecialFilter_SkipFileEntry(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []SpecialOption
+		mode    fs.FileMode
+		want    bool
+	}{
+		{"Regular", nil, 0, false},
+		{"Named pipe", nil, fs.ModeNamedPipe, true},
+		{"Socket", nil, fs.ModeSocket, true},
+		{"Char device", nil, fs.ModeDevice | fs.ModeCharDevice, true},
+		{"Irregular", nil, fs.ModeIrregular, true},
+		{"Link", nil, fs.ModeSymlink, true},
+		{"Devices read", []SpecialOption{WithSpecialDevices()}, fs.ModeDevice | fs.ModeCharDevice, false},
+		{"Devices read pipe", []SpecialOption{WithSpecialDevices()}, fs.ModeNamedPipe, false},
+		{"Devices read link", []SpecialOption{WithSpecialDevices()}, fs.ModeSymlink, true},
+		{"Links read", []SpecialOption{WithSpecialLinks()}, fs.ModeSymlink, false},
+		{"Links read pipe", []SpecialOption{WithSpecialLinks()}, fs.ModeNamedPipe, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewSpecial(tt.options...)
+			if got := filter.SkipFileEntry(base.DirEntry{Path: "a", Mode: tt.mode}); got != tt.want {
+				t.Errorf("SkipFileEntry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpecialFilter_SkipSearchResult(t *testing.T) {
+	filter := NewSpecial()
+
+	skip := filter.SkipSearchResult(base.SearchResult{})
+	if skip {
+		t.Error("Returned true")
+	}
+}
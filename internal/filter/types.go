@@ -0,0 +1,140 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pi-kei/mgrep/internal/base"
+	"github.com/pi-kei/mgrep/internal/glob"
+)
+
+var (
+	ErrUnknownType    = errors.New("unknown file type")
+	ErrInvalidTypeAdd = errors.New("file type definition must look like name:glob")
+)
+
+// Globs of file types by type name. Globs are matched against base names of files
+type FileTypes map[string][]string
+
+// Returns built-in file types. Returned map can be changed
+func DefaultFileTypes() FileTypes {
+	return FileTypes{
+		"c":      {"*.c", "*.h"},
+		"cpp":    {"*.cpp", "*.cc", "*.cxx", "*.hpp", "*.hh", "*.hxx", "*.h"},
+		"cs":     {"*.cs"},
+		"css":    {"*.css", "*.scss", "*.sass", "*.less"},
+		"docker": {"Dockerfile", "Dockerfile.*", "*.dockerfile", ".dockerignore"},
+		"go":     {"*.go", "go.mod", "go.sum", "go.work"},
+		"html":   {"*.html", "*.htm", "*.xhtml"},
+		"java":   {"*.java", "*.jsp"},
+		"js":     {"*.js", "*.mjs", "*.cjs", "*.jsx"},
+		"json":   {"*.json", "*.jsonl"},
+		"kotlin": {"*.kt", "*.kts"},
+		"make":   {"Makefile", "makefile", "GNUmakefile", "*.mk", "*.mak"},
+		"md":     {"*.md", "*.markdown"},
+		"proto":  {"*.proto"},
+		"py":     {"*.py", "*.pyi"},
+		"rb":     {"*.rb", "Gemfile", "Rakefile"},
+		"rust":   {"*.rs"},
+		"sh":     {"*.sh", "*.bash", "*.zsh"},
+		"sql":    {"*.sql"},
+		"swift":  {"*.swift"},
+		"toml":   {"*.toml"},
+		"ts":     {"*.ts", "*.mts", "*.cts", "*.tsx"},
+		"xml":    {"*.xml", "*.xsd", "*.xsl"},
+		"yaml":   {"*.yaml", "*.yml"},
+	}
+}
+
+// Adds a glob to a type from a definition like name:glob. Type is created if it does not exist
+func (f FileTypes) Add(definition string) error {
+	name, typeGlob, ok := strings.Cut(definition, ":")
+	if !ok || len(name) == 0 || len(typeGlob) == 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidTypeAdd, definition)
+	}
+	if _, err := glob.Compile(typeGlob, false); err != nil {
+		return err
+	}
+	f[name] = append(f[name], typeGlob)
+	return nil
+}
+
+// Returns names of types in order
+func (f FileTypes) Names() []string {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Compiles globs of types to a single list
+func (f FileTypes) compile(names []string) ([]*glob.Glob, error) {
+	var globs []*glob.Glob
+	for _, name := range names {
+		typeGlobs, ok := f[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownType, name)
+		}
+		for _, typeGlob := range typeGlobs {
+			compiled, err := glob.Compile(typeGlob, false)
+			if err != nil {
+				return nil, err
+			}
+			globs = append(globs, compiled)
+		}
+	}
+	return globs, nil
+}
+
+type Type struct {
+	selected []*glob.Glob // files must match one of them when not empty
+	excluded []*glob.Glob // files that match one of them are skipped
+}
+
+// Filter that keeps only files of selected types and skips files of excluded types.
+// Directories are never skipped. Returns error if a type is unknown
+func NewType(fileTypes FileTypes, selected []string, excluded []string) (base.Filter, error) {
+	selectedGlobs, err := fileTypes.compile(selected)
+	if err != nil {
+		return nil, err
+	}
+	excludedGlobs, err := fileTypes.compile(excluded)
+	if err != nil {
+		return nil, err
+	}
+	return &Type{selectedGlobs, excludedGlobs}, nil
+}
+
+func (t *Type) SkipDirEntry(dirEntry base.DirEntry) bool {
+	return false
+}
+
+func (t *Type) SkipFileEntry(fileEntry base.DirEntry) bool {
+	// Members of archives are separated by / on every platform
+	name := filepath.Base(fileEntry.Path)
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	if len(t.selected) > 0 && !matchAny(t.selected, name) {
+		return true
+	}
+	return matchAny(t.excluded, name)
+}
+
+func (t *Type) SkipSearchResult(searchResult base.SearchResult) bool {
+	return false
+}
+
+func matchAny(globs []*glob.Glob, name string) bool {
+	for _, g := range globs {
+		if g.Match(name) {
+			return true
+		}
+	}
+	return false
+}
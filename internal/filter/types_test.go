@@ -0,0 +1,72 @@
+package filter
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+func TestTypeFilter_SkipFileEntry(t *testing.T) {
+	fileTypes := DefaultFileTypes()
+	if err := fileTypes.Add("custom:*.foo"); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	tests := []struct {
+		name     string
+		selected []string
+		excluded []string
+		path     string
+		want     bool
+	}{
+		{"No types", nil, nil, "a/b.txt", false},
+		{"Selected", []string{"go"}, nil, "a/b.go", false},
+		{"Not selected", []string{"go"}, nil, "a/b.go.txt", true},
+		{"Well-known name", []string{"make"}, nil, "a/Makefile", false},
+		{"Several selected", []string{"js", "ts"}, nil, "a/b.ts", false},
+		{"Excluded", nil, []string{"js"}, "a/b.js", true},
+		{"Not excluded", nil, []string{"js"}, "a/b.json", false},
+		{"Selected and excluded", []string{"cpp"}, []string{"c"}, "a/b.h", true},
+		{"Custom", []string{"custom"}, nil, "a/b.foo", false},
+		{"Archive member", []string{"yaml"}, nil, "a/release.zip!/lib/config.yml", false},
+		{"Dir in name", []string{"go"}, nil, "a.go/b", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewType(fileTypes, tt.selected, tt.excluded)
+			if err != nil {
+				t.Fatalf("NewType error: %v", err)
+			}
+			if got := filter.SkipFileEntry(base.DirEntry{Path: tt.path}); got != tt.want {
+				t.Errorf("SkipFileEntry(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+			if filter.SkipDirEntry(base.DirEntry{Path: tt.path, IsDir: true}) {
+				t.Errorf("SkipDirEntry(%q) returned true", tt.path)
+			}
+		})
+	}
+}
+
+func TestFileTypes(t *testing.T) {
+	fileTypes := FileTypes{}
+	for _, definition := range []string{"a:*.a", "a:*.aa", "b:B"} {
+		if err := fileTypes.Add(definition); err != nil {
+			t.Errorf("Add(%q) error: %v", definition, err)
+		}
+	}
+	if !slices.Equal(fileTypes.Names(), []string{"a", "b"}) || !slices.Equal(fileTypes["a"], []string{"*.a", "*.aa"}) {
+		t.Errorf("Types are %v", fileTypes)
+	}
+	for _, definition := range []string{"a", ":*.a", "a:", "a:[\\"} {
+		if err := fileTypes.Add(definition); err == nil {
+			t.Errorf("Add(%q) returned no error", definition)
+		}
+	}
+	if _, err := NewType(fileTypes, []string{"c"}, nil); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("NewType error = %v, want %v", err, ErrUnknownType)
+	}
+	if _, err := NewType(fileTypes, nil, []string{"c"}); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("NewType error = %v, want %v", err, ErrUnknownType)
+	}
+}
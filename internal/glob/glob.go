@@ -0,0 +1,127 @@
+package glob
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Compiled glob pattern that is matched against slash separated paths
+type Glob struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// Compiles a glob pattern. Supported syntax:
+//   - * matches any sequence of characters except /
+//   - ? matches any single character except /
+//   - [abc], [a-z] match a character from a class, [!abc] or [^abc] match a character not in a class except /
+//   - ** as a whole path segment matches any number of path segments including none
+//   - \ escapes the next character
+func Compile(pattern string, ignoreCase bool) (*Glob, error) {
+	var sb strings.Builder
+	if ignoreCase {
+		sb.WriteString("(?i)")
+	}
+	sb.WriteString("(?s)^")
+	for i := 0; i < len(pattern); {
+		switch c := pattern[i]; c {
+		case '*':
+			if strings.HasPrefix(pattern[i:], "**") && (i == 0 || pattern[i-1] == '/') {
+				rest := pattern[i+2:]
+				if len(rest) == 0 {
+					sb.WriteString(".*")
+					i += 2
+					continue
+				}
+				if rest[0] == '/' {
+					sb.WriteString("(?:.*/)?")
+					i += 3
+					continue
+				}
+			}
+			for i < len(pattern) && pattern[i] == '*' {
+				i++
+			}
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+			i++
+		case '[':
+			class, size := compileClass(pattern[i:])
+			if size == 0 {
+				sb.WriteString(`\[`)
+				i++
+				continue
+			}
+			sb.WriteString(class)
+			i += size
+		case '\\':
+			if i == len(pattern)-1 {
+				return nil, errors.New("trailing backslash in glob pattern")
+			}
+			r, size := utf8.DecodeRuneInString(pattern[i+1:])
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+			i += 1 + size
+		default:
+			r, size := utf8.DecodeRuneInString(pattern[i:])
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+			i += size
+		}
+	}
+	sb.WriteString("$")
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return nil, err
+	}
+	return &Glob{pattern, re}, nil
+}
+
+// Compiles character class that starts at the beginning of a pattern.
+// Returns zero size if class is not closed
+func compileClass(pattern string) (string, int) {
+	var sb strings.Builder
+	sb.WriteString("[")
+	i := 1
+	negated := i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^')
+	if negated {
+		sb.WriteString("^/")
+		i++
+	}
+	for first := true; i < len(pattern); first = false {
+		c := pattern[i]
+		if c == ']' && !first {
+			sb.WriteString("]")
+			return sb.String(), i + 1
+		}
+		if c == '\\' && i < len(pattern)-1 {
+			i++
+		}
+		r, size := utf8.DecodeRuneInString(pattern[i:])
+		if r == '-' && !first && i < len(pattern)-1 && pattern[i+1] != ']' {
+			sb.WriteRune(r)
+		} else if r < utf8.RuneSelf && !isAlphanumeric(byte(r)) {
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune(r)
+		}
+		i += size
+	}
+	return "", 0
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// Checks if a whole slash separated path matches the pattern
+func (g *Glob) Match(path string) bool {
+	return g.re.MatchString(path)
+}
+
+// Returns source pattern
+func (g *Glob) String() string {
+	return g.pattern
+}
@@ -0,0 +1,64 @@
+package glob
+
+import (
+	"testing"
+)
+
+func TestGlob_Match(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		ignoreCase bool
+		path       string
+		match      bool
+	}{
+		{"*.go", false, "main.go", true},
+		{"*.go", false, "cmd/main.go", false},
+		{"*.go", false, "main.GO", false},
+		{"*.go", true, "main.GO", true},
+		{"?.txt", false, "a.txt", true},
+		{"?.txt", false, "ab.txt", false},
+		{"?", false, "/", false},
+		{"[abc].txt", false, "b.txt", true},
+		{"[abc].txt", false, "d.txt", false},
+		{"[!abc].txt", false, "d.txt", true},
+		{"[^abc].txt", false, "a.txt", false},
+		{"[!abc]", false, "/", false},
+		{"[a-c]x", false, "bx", true},
+		{"[a-c]x", false, "dx", false},
+		{"[]]", false, "]", true},
+		{"[a-]", false, "-", true},
+		{"[.]", false, "x", false},
+		{"[abc", false, "[abc", true},
+		{"**/foo", false, "foo", true},
+		{"**/foo", false, "a/b/foo", true},
+		{"**/foo", false, "a/b/xfoo", false},
+		{"foo/**", false, "foo/a/b", true},
+		{"foo/**", false, "foo", false},
+		{"a/**/b", false, "a/b", true},
+		{"a/**/b", false, "a/x/y/b", true},
+		{"a/**/b", false, "ab", false},
+		{"a**b", false, "axxb", true},
+		{"a**b", false, "ax/xb", false},
+		{"\\*", false, "*", true},
+		{"\\*", false, "a", false},
+		{"a+b(c)", false, "a+b(c)", true},
+		{"тест*", false, "тест.txt", true},
+	}
+	for _, test := range tests {
+		glob, err := Compile(test.pattern, test.ignoreCase)
+		if err != nil {
+			t.Errorf("Compile(%q) returned error %v", test.pattern, err)
+			continue
+		}
+		if match := glob.Match(test.path); match != test.match {
+			t.Errorf("%q.Match(%q) returned %v", test.pattern, test.path, match)
+		}
+	}
+}
+
+func TestGlob_Compile_Error(t *testing.T) {
+	_, err := Compile("abc\\", false)
+	if err == nil {
+		t.Error("Compile returned no error")
+	}
+}
@@ -0,0 +1,89 @@
+package glob
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Glob rule with gitignore semantics
+type Rule struct {
+	glob    *Glob
+	Negated bool // rule starts with !
+	DirOnly bool // rule ends with / and matches only directories
+}
+
+// Parses a single line of an ignore file.
+// Returns false if line is blank or a comment.
+// Rules that have / at the start or in the middle are anchored to the directory of the ignore file,
+// other rules match at any level below it
+func ParseRule(line string, ignoreCase bool) (Rule, bool, error) {
+	line = strings.TrimSuffix(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if len(line) == 0 || line[0] == '#' {
+		return Rule{}, false, nil
+	}
+	rule := Rule{}
+	if line[0] == '!' {
+		rule.Negated = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") && !strings.HasSuffix(line, "\\/") {
+		rule.DirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if len(line) == 0 {
+		return Rule{}, false, nil
+	}
+	if strings.HasPrefix(line, "/") {
+		line = line[1:]
+	} else if !strings.Contains(line, "/") {
+		line = "**/" + line
+	}
+	glob, err := Compile(line, ignoreCase)
+	if err != nil {
+		return Rule{}, false, err
+	}
+	rule.glob = glob
+	return rule, true, nil
+}
+
+// Checks if a slash separated path relative to the directory of the rule matches it
+func (r Rule) Match(path string, isDir bool) bool {
+	if r.DirOnly && !isDir {
+		return false
+	}
+	return r.glob.Match(path)
+}
+
+// Ordered list of rules where later rules take precedence
+type Rules []Rule
+
+// Parses rules from content of an ignore file
+func ParseRules(reader io.Reader, ignoreCase bool) (Rules, error) {
+	var rules Rules
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		rule, ok, err := ParseRule(scanner.Text(), ignoreCase)
+		if err != nil {
+			return rules, err
+		}
+		if ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, scanner.Err()
+}
+
+// Finds the last rule that matches a slash separated path.
+// Returns whether any rule matched and whether that rule is negated
+func (r Rules) Match(path string, isDir bool) (matched bool, negated bool) {
+	for i := len(r) - 1; i >= 0; i-- {
+		if r[i].Match(path, isDir) {
+			return true, r[i].Negated
+		}
+	}
+	return false, false
+}
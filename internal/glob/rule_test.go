@@ -0,0 +1,96 @@
+package glob
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		line    string
+		ok      bool
+		negated bool
+		dirOnly bool
+	}{
+		{"", false, false, false},
+		{"   ", false, false, false},
+		{"# comment", false, false, false},
+		{"\\#file", true, false, false},
+		{"!keep", true, true, false},
+		{"\\!file", true, false, false},
+		{"build/", true, false, true},
+		{"!build/", true, true, true},
+		{"/", false, false, false},
+	}
+	for _, test := range tests {
+		rule, ok, err := ParseRule(test.line, false)
+		if err != nil {
+			t.Errorf("ParseRule(%q) returned error %v", test.line, err)
+		}
+		if ok != test.ok || rule.Negated != test.negated || rule.DirOnly != test.dirOnly {
+			t.Errorf("ParseRule(%q) returned %v %v", test.line, rule, ok)
+		}
+	}
+}
+
+func TestRule_Match(t *testing.T) {
+	tests := []struct {
+		line  string
+		path  string
+		isDir bool
+		match bool
+	}{
+		{"*.log", "a.log", false, true},
+		{"*.log", "a/b/c.log", false, true},
+		{"/*.log", "a/b/c.log", false, false},
+		{"/*.log", "c.log", false, true},
+		{"doc/*.txt", "doc/a.txt", false, true},
+		{"doc/*.txt", "x/doc/a.txt", false, false},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"build/", "a/build", true, true},
+		{"vendor", "myvendorlib.go", false, false},
+		{"trailing  ", "trailing", false, true},
+		{"escaped\\ ", "escaped ", false, true},
+		{"\\#file", "#file", false, true},
+		{"crlf\r", "crlf", false, true},
+	}
+	for _, test := range tests {
+		rule, ok, err := ParseRule(test.line, false)
+		if err != nil || !ok {
+			t.Errorf("ParseRule(%q) returned %v %v", test.line, ok, err)
+			continue
+		}
+		if match := rule.Match(test.path, test.isDir); match != test.match {
+			t.Errorf("%q.Match(%q, %v) returned %v", test.line, test.path, test.isDir, match)
+		}
+	}
+}
+
+func TestRules_Match(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader("# logs\n*.log\n!important.log\n\nnode_modules/\n"), false)
+	if err != nil {
+		t.Fatalf("ParseRules returned error %v", err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("ParseRules returned %v rules", len(rules))
+	}
+	tests := []struct {
+		path    string
+		isDir   bool
+		matched bool
+		negated bool
+	}{
+		{"a.log", false, true, false},
+		{"a/important.log", false, true, true},
+		{"a/node_modules", true, true, false},
+		{"a/node_modules", false, false, false},
+		{"a.txt", false, false, false},
+	}
+	for _, test := range tests {
+		matched, negated := rules.Match(test.path, test.isDir)
+		if matched != test.matched || negated != test.negated {
+			t.Errorf("Match(%q, %v) returned %v %v", test.path, test.isDir, matched, negated)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package glob
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrEmptyGlob = errors.New("empty glob")
+
+// Globs that select paths of a search with gitignore semantics.
+// Globs that start with ! exclude paths and later globs take precedence.
+// When some globs do not start with ! then files must match one of them
+type Set struct {
+	rules    Rules
+	includes bool // whether any glob selects files
+}
+
+// Adds a glob. Glob that has / at the start or in the middle is anchored to the root of a search,
+// other globs match base names at any level
+func (s *Set) Add(pattern string, ignoreCase bool) error {
+	// Globs are not lines of a file, so # does not start a comment
+	if strings.HasPrefix(pattern, "#") {
+		pattern = "\\" + pattern
+	}
+	rule, ok, err := ParseRule(pattern, ignoreCase)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrEmptyGlob
+	}
+	s.rules = append(s.rules, rule)
+	if !rule.Negated {
+		s.includes = true
+	}
+	return nil
+}
+
+// Checks if there are no globs
+func (s *Set) Empty() bool {
+	return len(s.rules) == 0
+}
+
+// Checks if a slash separated path relative to the root of a search is selected.
+// Directory is not selected only when it is excluded, so files in it can still be selected
+func (s *Set) Selected(path string, isDir bool) bool {
+	matched, negated := s.rules.Match(path, isDir)
+	if matched {
+		return !negated
+	}
+	return isDir || !s.includes
+}
+
+// Returns slash separated path of an entry relative to the root of a search.
+// Members of an archive that is the root are relative to it too. Root itself is matched by its base name
+func RelativePath(root string, path string) string {
+	if path == root {
+		return filepath.ToSlash(filepath.Base(path))
+	}
+	if root == "." && !filepath.IsAbs(path) {
+		return filepath.ToSlash(path)
+	}
+	if rest, ok := strings.CutPrefix(path, root); ok {
+		if archiveRest, ok := strings.CutPrefix(rest, "!/"); ok {
+			return archiveRest
+		}
+		if len(rest) > 0 && os.IsPathSeparator(rest[0]) {
+			return filepath.ToSlash(rest[1:])
+		}
+		if len(rest) > 0 && strings.HasSuffix(root, string(filepath.Separator)) {
+			return filepath.ToSlash(rest)
+		}
+	}
+	if rel, err := filepath.Rel(root, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(path)
+}
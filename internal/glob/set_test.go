@@ -0,0 +1,92 @@
+package glob
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSet_Selected(t *testing.T) {
+	tests := []struct {
+		name  string
+		globs []string
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"No globs", nil, "a/b.txt", false, true},
+		{"Basename", []string{"*.go"}, "a/b.go", false, true},
+		{"Basename not matched", []string{"*.go"}, "a/b.txt", false, false},
+		{"Dir of includes", []string{"*.go"}, "a", true, true},
+		{"Excluded dir", []string{"!vendor"}, "a/vendor", true, false},
+		{"Not excluded dir", []string{"!vendor"}, "a/myvendorlib.go", false, true},
+		{"Anchored", []string{"/vendor"}, "a/vendor", true, true},
+		{"Anchored not matched", []string{"!/vendor"}, "a/vendor", true, true},
+		{"Anchored matched", []string{"!/vendor"}, "vendor", true, false},
+		{"Double star", []string{"src/**/*.go"}, "src/a/b/c.go", false, true},
+		{"Double star none", []string{"src/**/*.go"}, "src/c.go", false, true},
+		{"Double star not matched", []string{"src/**/*.go"}, "lib/c.go", false, false},
+		{"Later negated", []string{"*.go", "!*_test.go"}, "a/b_test.go", false, false},
+		{"Later included", []string{"!*_test.go", "*.go"}, "a/b_test.go", false, true},
+		{"Only excludes", []string{"!*.log"}, "a/b.txt", false, true},
+		{"Dir only", []string{"!build/"}, "build", false, true},
+		{"Dir only matched", []string{"!build/"}, "build", true, false},
+		{"Comment sign", []string{"#a"}, "#a", false, true},
+		{"Case", []string{"*.GO"}, "a.go", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var set Set
+			for _, pattern := range tt.globs {
+				if err := set.Add(pattern, false); err != nil {
+					t.Fatalf("Add(%q) error: %v", pattern, err)
+				}
+			}
+			if got := set.Selected(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("Selected(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSet_Add(t *testing.T) {
+	var set Set
+	if !set.Empty() {
+		t.Errorf("New set is not empty")
+	}
+	if err := set.Add("*.GO", true); err != nil || set.Empty() || !set.Selected("a.go", false) {
+		t.Errorf("Add of case-insensitive glob returned %v", err)
+	}
+	for _, pattern := range []string{"", "!", "/"} {
+		if err := set.Add(pattern, false); !errors.Is(err, ErrEmptyGlob) {
+			t.Errorf("Add(%q) error = %v, want %v", pattern, err, ErrEmptyGlob)
+		}
+	}
+	if err := set.Add("a\\", false); err == nil {
+		t.Errorf("Add of invalid glob returned no error")
+	}
+}
+
+func TestRelativePath(t *testing.T) {
+	tests := []struct {
+		root string
+		path string
+		want string
+	}{
+		{".", ".", "."},
+		{".", "a", "a"},
+		{".", filepath.Join("a", "b"), "a/b"},
+		{"a", filepath.Join("a", "b", "c"), "b/c"},
+		{"a" + string(filepath.Separator), filepath.Join("a", "b"), "b"},
+		{"./a", filepath.Join("a", "b"), "b"},
+		{"a", "a", "a"},
+		{filepath.Join("a", "b.txt"), filepath.Join("a", "b.txt"), "b.txt"},
+		{"a.zip", "a.zip!/lib/b", "lib/b"},
+		{"a", filepath.Join("a", "b.zip!/lib/c"), "b.zip!/lib/c"},
+	}
+	for _, tt := range tests {
+		if got := RelativePath(tt.root, tt.path); got != tt.want {
+			t.Errorf("RelativePath(%q, %q) = %q, want %q", tt.root, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package matcher
+
+import (
+	"strings"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+// Finds many non-empty literal strings at once using Aho-Corasick automaton.
+// When several strings match at the same position the longest one is reported,
+// if they are equal then the first one is reported.
+// Case-insensitive mode folds ASCII letters only
+type AhoCorasick struct {
+	classes [256]int32 // maps bytes to columns of transitions table. Bytes that are not in patterns share column 0
+	stride  int32      // number of columns
+	next    []int32    // transitions of a complete automaton. Row per state
+	outputs [][]int32  // indexes of patterns that end in a state
+	dict    []int32    // nearest state on a failure path that has outputs or -1
+	depths  []int32    // lengths of prefixes of patterns that states stand for
+	lengths []int      // lengths of patterns
+	newLine bool       // whether any of patterns has a new line character
+}
+
+func NewAhoCorasick(patterns []string, ignoreCase bool) *AhoCorasick {
+	a := &AhoCorasick{lengths: make([]int, len(patterns))}
+	fold := func(c byte) byte {
+		if ignoreCase {
+			return asciiLower[c]
+		}
+		return c
+	}
+	a.stride = 1
+	for _, pattern := range patterns {
+		for i := 0; i < len(pattern); i++ {
+			if c := fold(pattern[i]); a.classes[c] == 0 {
+				a.classes[c] = a.stride
+				a.stride++
+			}
+		}
+	}
+	if ignoreCase {
+		for c := 'A'; c <= 'Z'; c++ {
+			a.classes[c] = a.classes[c-'A'+'a']
+		}
+	}
+
+	a.addState()
+	for index, pattern := range patterns {
+		a.lengths[index] = len(pattern)
+		a.newLine = a.newLine || strings.Contains(pattern, "\n")
+		state := int32(0)
+		for i := 0; i < len(pattern); i++ {
+			cell := state*a.stride + a.classes[pattern[i]]
+			if a.next[cell] == 0 {
+				child := a.addState()
+				a.next[cell] = child
+				a.depths[child] = a.depths[state] + 1
+			}
+			state = a.next[cell]
+		}
+		a.outputs[state] = append(a.outputs[state], int32(index))
+	}
+
+	// Breadth-first traversal that turns trie into a complete automaton
+	fail := make([]int32, len(a.outputs))
+	queue := []int32{}
+	for class := int32(1); class < a.stride; class++ {
+		if child := a.next[class]; child != 0 {
+			queue = append(queue, child)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		if len(a.outputs[fail[state]]) > 0 {
+			a.dict[state] = fail[state]
+		} else {
+			a.dict[state] = a.dict[fail[state]]
+		}
+		for class := int32(1); class < a.stride; class++ {
+			cell := state*a.stride + class
+			failCell := fail[state]*a.stride + class
+			child := a.next[cell]
+			if child == 0 {
+				a.next[cell] = a.next[failCell]
+				continue
+			}
+			fail[child] = a.next[failCell]
+			queue = append(queue, child)
+		}
+	}
+	return a
+}
+
+func (a *AhoCorasick) addState() int32 {
+	a.next = append(a.next, make([]int32, a.stride)...)
+	a.outputs = append(a.outputs, nil)
+	a.dict = append(a.dict, -1)
+	a.depths = append(a.depths, 0)
+	return int32(len(a.outputs) - 1)
+}
+
+func (a *AhoCorasick) FindAll(b []byte, n int) []base.Match {
+	literalMatches := a.findAll(b, n)
+	if literalMatches == nil {
+		return nil
+	}
+	matches := make([]base.Match, len(literalMatches))
+	for i, match := range literalMatches {
+		matches[i] = base.Match{StartIndex: match.start, EndIndex: match.end, Pattern: match.pattern}
+	}
+	return matches
+}
+
+func (a *AhoCorasick) MultiLine() bool {
+	return a.newLine
+}
+
+// Returns nil because a match may contain any of the patterns
+func (a *AhoCorasick) Literals() []string {
+	return nil
+}
+
+type literalMatch struct {
+	start   int
+	end     int
+	pattern int
+}
+
+// Finds leftmost-longest successive non-overlapping matches.
+// Candidate match is reported at the end of text or once no match can start at or before its start,
+// that is when the current state stands for a text that starts after it.
+// Search goes on from the end of the reported match with the automaton reset
+func (a *AhoCorasick) findAll(b []byte, n int) []literalMatch {
+	var matches []literalMatch
+	var candidate literalMatch // leftmost-longest match since the last reported one. Not set when end is zero
+	state := int32(0)
+	for i := 0; n < 0 || len(matches) < n; i++ {
+		final := i == len(b)
+		if !final {
+			state = a.next[state*a.stride+a.classes[b[i]]]
+			final = i+1-int(a.depths[state]) > candidate.start
+		}
+		if candidate.end > 0 && final {
+			matches = append(matches, candidate)
+			i, state, candidate = candidate.end-1, 0, literalMatch{}
+			continue
+		}
+		if i == len(b) {
+			break
+		}
+		for s := state; s >= 0; s = a.dict[s] {
+			for _, pattern := range a.outputs[s] {
+				start := i + 1 - a.lengths[pattern]
+				if candidate.end == 0 || start < candidate.start || (start == candidate.start && i+1 > candidate.end) {
+					candidate = literalMatch{start, i + 1, int(pattern)}
+				}
+			}
+		}
+	}
+	return matches
+}
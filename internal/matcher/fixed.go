@@ -0,0 +1,67 @@
+package matcher
+
+import (
+	"regexp"
+	"unicode"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+// Maps each byte to its lower case when it is an ASCII letter
+var asciiLower [256]byte
+
+func init() {
+	for i := range asciiLower {
+		asciiLower[i] = byte(i)
+		if i >= 'A' && i <= 'Z' {
+			asciiLower[i] = byte(i) + 'a' - 'A'
+		}
+	}
+}
+
+// Creates a matcher of literal strings.
+// Uses Horspool for a single string and Aho-Corasick for many of them.
+// When several strings match at the same position the longest one is reported.
+// Case-insensitive matching of strings that have non-ASCII letters and matching of empty strings
+// fall back to regexp
+func NewFixed(patterns []string, ignoreCase bool) base.Matcher {
+	fallback := false
+	for _, pattern := range patterns {
+		if len(pattern) == 0 || (ignoreCase && hasNonASCIILetter(pattern)) {
+			fallback = true
+			break
+		}
+	}
+	if fallback {
+		return newFixedRegexp(patterns, ignoreCase)
+	}
+	if len(patterns) == 1 {
+		return NewHorspool(patterns[0], ignoreCase)
+	}
+	return NewAhoCorasick(patterns, ignoreCase)
+}
+
+func hasNonASCIILetter(s string) bool {
+	for _, r := range s {
+		if r >= 0x80 && unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func newFixedRegexp(patterns []string, ignoreCase bool) *Regexp {
+	quoted := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		quoted[i] = regexp.QuoteMeta(pattern)
+		if ignoreCase {
+			quoted[i] = "(?i)" + quoted[i]
+		}
+	}
+	r, err := CompileRegexps(quoted)
+	if err != nil {
+		panic(err)
+	}
+	r.re.Longest()
+	return r
+}
@@ -0,0 +1,115 @@
+package matcher
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+func TestHorspool_FindAllIndex(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		ignoreCase bool
+		text       string
+		matches    []base.Match
+	}{
+		{"abc", false, "abc xabc ABC", []base.Match{{StartIndex: 0, EndIndex: 3}, {StartIndex: 5, EndIndex: 8}}},
+		{"abc", true, "abc xabc ABC", []base.Match{{StartIndex: 0, EndIndex: 3}, {StartIndex: 5, EndIndex: 8}, {StartIndex: 9, EndIndex: 12}}},
+		{"aA", true, "aaaa", []base.Match{{StartIndex: 0, EndIndex: 2}, {StartIndex: 2, EndIndex: 4}}},
+		{"x", false, "abc", nil},
+		{"long pattern", true, "short", nil},
+		{"тест", true, "ТЕСТ тест", []base.Match{{StartIndex: 9, EndIndex: 17}}},
+	}
+	for _, test := range tests {
+		h := NewHorspool(test.pattern, test.ignoreCase)
+		matches := h.FindAll([]byte(test.text), -1)
+		if !reflect.DeepEqual(matches, test.matches) {
+			t.Errorf("%q.FindAll(%q) returned %v", test.pattern, test.text, matches)
+		}
+		if len(test.matches) > 1 {
+			matches = h.FindAll([]byte(test.text), 1)
+			if !reflect.DeepEqual(matches, test.matches[:1]) {
+				t.Errorf("%q.FindAll(%q, 1) returned %v", test.pattern, test.text, matches)
+			}
+		}
+	}
+}
+
+func TestAhoCorasick_FindAllIndex(t *testing.T) {
+	tests := []struct {
+		patterns   []string
+		ignoreCase bool
+		text       string
+		matches    []literalMatch
+	}{
+		{[]string{"he", "she", "his", "hers"}, false, "ushers", []literalMatch{{1, 4, 1}}},
+		{[]string{"he", "she", "his", "hers"}, false, "hershe his", []literalMatch{{0, 4, 3}, {4, 6, 0}, {7, 10, 2}}},
+		{[]string{"bc", "abcd"}, false, "abcd", []literalMatch{{0, 4, 1}}},
+		{[]string{"ab", "ab"}, false, "ab", []literalMatch{{0, 2, 0}}},
+		{[]string{"Foo", "BAR"}, true, "foo bar FOO", []literalMatch{{0, 3, 0}, {4, 7, 1}, {8, 11, 0}}},
+		{[]string{"Foo", "BAR"}, false, "foo bar FOO", nil},
+		{[]string{"ab", "c", "abcd"}, false, "abcx c", []literalMatch{{0, 2, 0}, {2, 3, 1}, {5, 6, 1}}},
+		{[]string{"aaa"}, false, "aaaaaaa", []literalMatch{{0, 3, 0}, {3, 6, 0}}},
+	}
+	for _, test := range tests {
+		a := NewAhoCorasick(test.patterns, test.ignoreCase)
+		matches := a.findAll([]byte(test.text), -1)
+		if !reflect.DeepEqual(matches, test.matches) {
+			t.Errorf("%q.findAll(%q) returned %v", test.patterns, test.text, matches)
+		}
+		if len(test.matches) > 1 {
+			matches = a.findAll([]byte(test.text), 1)
+			if !reflect.DeepEqual(matches, test.matches[:1]) {
+				t.Errorf("%q.findAll(%q, 1) returned %v", test.patterns, test.text, matches)
+			}
+		}
+	}
+}
+
+// Compares literal matchers with regexp that uses leftmost-longest matching
+func TestNewFixed_Random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	alphabet := "abAB c"
+	randomString := func(maxLen int) string {
+		var sb strings.Builder
+		for i := rnd.Intn(maxLen) + 1; i > 0; i-- {
+			sb.WriteByte(alphabet[rnd.Intn(len(alphabet))])
+		}
+		return sb.String()
+	}
+	for i := 0; i < 2000; i++ {
+		patterns := make([]string, rnd.Intn(4)+1)
+		for j := range patterns {
+			patterns[j] = randomString(4)
+		}
+		ignoreCase := rnd.Intn(2) == 0
+		text := randomString(40)
+		expected := newFixedRegexp(patterns, ignoreCase).FindAll([]byte(text), -1)
+		matches := NewFixed(patterns, ignoreCase).FindAll([]byte(text), -1)
+		if !reflect.DeepEqual(matches, expected) {
+			t.Fatalf("%q (ignore case %v) in %q returned %v expected %v", patterns, ignoreCase, text, matches, expected)
+		}
+	}
+}
+
+func TestNewFixed_Fallback(t *testing.T) {
+	if _, ok := NewFixed([]string{"тест"}, true).(*Regexp); !ok {
+		t.Error("Non-ASCII case-insensitive pattern does not fall back to regexp")
+	}
+	if _, ok := NewFixed([]string{"a", ""}, false).(*Regexp); !ok {
+		t.Error("Empty pattern does not fall back to regexp")
+	}
+	if _, ok := NewFixed([]string{"тест"}, false).(*Horspool); !ok {
+		t.Error("Single pattern does not use Horspool")
+	}
+	if _, ok := NewFixed([]string{"a", "b"}, true).(*AhoCorasick); !ok {
+		t.Error("Many patterns do not use Aho-Corasick")
+	}
+	matches := NewFixed([]string{"тест"}, true).FindAll([]byte("ТЕСТ"), -1)
+	if !reflect.DeepEqual(matches, []base.Match{{StartIndex: 0, EndIndex: 8}}) {
+		t.Errorf("Fallback returned %v", matches)
+	}
+}
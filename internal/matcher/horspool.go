@@ -0,0 +1,80 @@
+package matcher
+
+import (
+	"bytes"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+// Finds a single non-empty literal string.
+// Case-insensitive mode uses Boyer-Moore-Horspool algorithm and folds ASCII letters only.
+// Case-sensitive mode relies on bytes.Index that is faster on most inputs
+type Horspool struct {
+	pattern    []byte // lower cased in case-insensitive mode
+	shift      [256]int
+	ignoreCase bool
+}
+
+func NewHorspool(pattern string, ignoreCase bool) *Horspool {
+	h := &Horspool{pattern: []byte(pattern), ignoreCase: ignoreCase}
+	if !ignoreCase {
+		return h
+	}
+	for i, c := range h.pattern {
+		h.pattern[i] = asciiLower[c]
+	}
+	last := len(h.pattern) - 1
+	for i := range h.shift {
+		h.shift[i] = len(h.pattern)
+	}
+	for i := 0; i < last; i++ {
+		h.shift[h.pattern[i]] = last - i
+		if h.pattern[i] >= 'a' && h.pattern[i] <= 'z' {
+			h.shift[h.pattern[i]-'a'+'A'] = last - i
+		}
+	}
+	return h
+}
+
+// Returns index of the first match at or after from, -1 if there is none
+func (h *Horspool) index(b []byte, from int) int {
+	if !h.ignoreCase {
+		if i := bytes.Index(b[from:], h.pattern); i >= 0 {
+			return from + i
+		}
+		return -1
+	}
+	last := len(h.pattern) - 1
+	for i := from; i+last < len(b); i += h.shift[b[i+last]] {
+		j := last
+		for j >= 0 && asciiLower[b[i+j]] == h.pattern[j] {
+			j--
+		}
+		if j < 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+func (h *Horspool) FindAll(b []byte, n int) []base.Match {
+	var matches []base.Match
+	for from := 0; n < 0 || len(matches) < n; {
+		i := h.index(b, from)
+		if i < 0 {
+			break
+		}
+		from = i + len(h.pattern)
+		matches = append(matches, base.Match{StartIndex: i, EndIndex: from})
+	}
+	return matches
+}
+
+func (h *Horspool) MultiLine() bool {
+	return bytes.IndexByte(h.pattern, '\n') >= 0
+}
+
+// Returns nil because looking for the literal is the matching itself
+func (h *Horspool) Literals() []string {
+	return nil
+}
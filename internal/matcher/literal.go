@@ -0,0 +1,23 @@
+package matcher
+
+import (
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+// Replacer that has no capture groups to expand
+type Literal struct{}
+
+// Creates a replacer that replaces every match with a template as is
+func NewLiteral() base.Replacer {
+	return &Literal{}
+}
+
+func (l *Literal) Replace(dst []byte, template string, src []byte, matches []base.Match) []byte {
+	last := 0
+	for _, match := range matches {
+		dst = append(dst, src[last:match.StartIndex]...)
+		dst = append(dst, template...)
+		last = match.EndIndex
+	}
+	return append(dst, src[last:]...)
+}
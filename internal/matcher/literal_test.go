@@ -0,0 +1,14 @@
+package matcher
+
+import (
+	"testing"
+)
+
+func TestLiteral_Replace(t *testing.T) {
+	m := NewFixed([]string{"ab", "c"}, false)
+	src := []byte("xabyc")
+	replaced := NewLiteral().Replace(nil, "$1", src, m.FindAll(src, -1))
+	if string(replaced) != "x$1y$1" {
+		t.Errorf("Replace returned %q", replaced)
+	}
+}
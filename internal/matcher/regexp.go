@@ -0,0 +1,204 @@
+package matcher
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+// Matcher backed by a regular expression of the standard library
+type Regexp struct {
+	re        *regexp.Regexp
+	groups    []int            // capture group of each pattern when several patterns are combined
+	patterns  []*regexp.Regexp // each pattern compiled separately when several patterns are combined
+	multiLine bool
+	literals  []string
+}
+
+func NewRegexp(re *regexp.Regexp) *Regexp {
+	r := &Regexp{re: re}
+	tree, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		// Expression is already compiled so it must parse. Assume the worst
+		r.multiLine = true
+		return r
+	}
+	tree = tree.Simplify()
+	r.multiLine = canMatchNewLine(tree)
+	r.literals = requiredLiterals(tree, nil)
+	return r
+}
+
+// Compiles expression that uses syntax of the regexp package
+func CompileRegexp(expr string) (*Regexp, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return NewRegexp(re), nil
+}
+
+// Combines several expressions into one. Matches report index of an expression that matched.
+// When several expressions match at the same position the longest match wins like in grep, the first one if they are equal.
+// Combined expression is leftmost-longest, so each expression prefers longer matches too
+func CompileRegexps(exprs []string) (*Regexp, error) {
+	if len(exprs) == 1 {
+		return CompileRegexp(exprs[0])
+	}
+	var sb strings.Builder
+	groups := make([]int, len(exprs))
+	patterns := make([]*regexp.Regexp, len(exprs))
+	group := 1
+	for i, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %d: %w", i+1, err)
+		}
+		if i > 0 {
+			sb.WriteString("|")
+		}
+		sb.WriteString("(")
+		sb.WriteString(expr)
+		sb.WriteString(")")
+		groups[i] = group
+		patterns[i] = re
+		group += 1 + re.NumSubexp()
+	}
+	r, err := CompileRegexp(sb.String())
+	if err != nil {
+		return nil, err
+	}
+	r.re.Longest()
+	r.groups = groups
+	r.patterns = patterns
+	return r, nil
+}
+
+func (r *Regexp) FindAll(b []byte, n int) []base.Match {
+	if r.groups != nil {
+		return r.findAllGroups(b, n)
+	}
+	indexes := r.re.FindAllIndex(b, n)
+	if indexes == nil {
+		return nil
+	}
+	matches := make([]base.Match, len(indexes))
+	for i, index := range indexes {
+		matches[i] = base.Match{StartIndex: index[0], EndIndex: index[1]}
+	}
+	return matches
+}
+
+func (r *Regexp) findAllGroups(b []byte, n int) []base.Match {
+	indexes := r.re.FindAllSubmatchIndex(b, n)
+	if indexes == nil {
+		return nil
+	}
+	matches := make([]base.Match, len(indexes))
+	for i, index := range indexes {
+		matches[i] = base.Match{StartIndex: index[0], EndIndex: index[1]}
+		for pattern, group := range r.groups {
+			if index[2*group] >= 0 {
+				matches[i].Pattern = pattern
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// Replaces matches with a template where $1, ${1} and ${name} are expanded
+// the same way regexp.Regexp.Expand does. Capture groups of each pattern are numbered
+// as if the pattern was searched alone
+func (r *Regexp) Replace(dst []byte, template string, src []byte, matches []base.Match) []byte {
+	indexes := r.re.FindAllSubmatchIndex(src, -1)
+	last := 0
+	next := 0
+	for _, match := range matches {
+		for next < len(indexes) && indexes[next][0] < match.StartIndex {
+			next++
+		}
+		dst = append(dst, src[last:match.StartIndex]...)
+		if next < len(indexes) && indexes[next][0] == match.StartIndex && indexes[next][1] == match.EndIndex {
+			dst = r.expand(dst, template, src, indexes[next], match.Pattern)
+		} else {
+			// Not a match of this regexp. Keep it as is
+			dst = append(dst, src[match.StartIndex:match.EndIndex]...)
+		}
+		last = match.EndIndex
+	}
+	return append(dst, src[last:]...)
+}
+
+func (r *Regexp) expand(dst []byte, template string, src []byte, index []int, pattern int) []byte {
+	if r.patterns == nil {
+		return r.re.Expand(dst, []byte(template), src, index)
+	}
+	re := r.patterns[pattern]
+	group := r.groups[pattern]
+	return re.Expand(dst, []byte(template), src, index[2*group:2*(group+1+re.NumSubexp())])
+}
+
+func (r *Regexp) MultiLine() bool {
+	return r.multiLine
+}
+
+func (r *Regexp) Literals() []string {
+	return r.literals
+}
+
+// Returns underlying regular expression
+func (r *Regexp) Regexp() *regexp.Regexp {
+	return r.re
+}
+
+func canMatchNewLine(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpAnyChar:
+		return true
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if r == '\n' {
+				return true
+			}
+		}
+		return false
+	case syntax.OpCharClass:
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			if re.Rune[i] <= '\n' && '\n' <= re.Rune[i+1] {
+				return true
+			}
+		}
+		return false
+	}
+	for _, sub := range re.Sub {
+		if canMatchNewLine(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// Collects case-sensitive literals that must be present in every match
+func requiredLiterals(re *syntax.Regexp, literals []string) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase == 0 {
+			literals = append(literals, string(re.Rune))
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			literals = requiredLiterals(sub, literals)
+		}
+	case syntax.OpCapture, syntax.OpPlus:
+		literals = requiredLiterals(re.Sub[0], literals)
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			literals = requiredLiterals(re.Sub[0], literals)
+		}
+	}
+	return literals
+}
@@ -0,0 +1,158 @@
+package matcher
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+func TestRegexp_FindAll(t *testing.T) {
+	r, err := CompileRegexp(`h\w`)
+	if err != nil {
+		t.Fatalf("CompileRegexp returned error %v", err)
+	}
+	matches := r.FindAll([]byte("hello hi"), -1)
+	if !reflect.DeepEqual(matches, []base.Match{{StartIndex: 0, EndIndex: 2}, {StartIndex: 6, EndIndex: 8}}) {
+		t.Errorf("FindAll returned %v", matches)
+	}
+	matches = r.FindAll([]byte("hello hi"), 1)
+	if !reflect.DeepEqual(matches, []base.Match{{StartIndex: 0, EndIndex: 2}}) {
+		t.Errorf("FindAll returned %v", matches)
+	}
+	matches = r.FindAll([]byte("xyz"), -1)
+	if matches != nil {
+		t.Errorf("FindAll returned %v", matches)
+	}
+	_, err = CompileRegexp(`(`)
+	if err == nil {
+		t.Error("CompileRegexp returned no error")
+	}
+}
+
+func TestRegexp_MultiLine(t *testing.T) {
+	tests := []struct {
+		expr      string
+		multiLine bool
+	}{
+		{`abc`, false},
+		{`a.c`, false},
+		{`(?s)a.c`, true},
+		{`a\nc`, true},
+		{`a\sc`, true},
+		{`a[^b]c`, true},
+		{`a[ \t]c`, false},
+		{`(?m)^a$`, false},
+		{`a(b|\n)`, true},
+	}
+	for _, test := range tests {
+		r, err := CompileRegexp(test.expr)
+		if err != nil {
+			t.Errorf("CompileRegexp(%q) returned error %v", test.expr, err)
+			continue
+		}
+		if multiLine := r.MultiLine(); multiLine != test.multiLine {
+			t.Errorf("%q.MultiLine() returned %v", test.expr, multiLine)
+		}
+	}
+}
+
+func TestRegexp_Literals(t *testing.T) {
+	tests := []struct {
+		expr     string
+		literals []string
+	}{
+		{`abc`, []string{"abc"}},
+		{`(?i)abc`, nil},
+		{`foo\w+bar`, []string{"foo", "bar"}},
+		{`(foo)+\d{2}(bar)`, []string{"foo", "bar"}},
+		{`foo|bar`, nil},
+		{`(foo)?bar`, []string{"bar"}},
+		{`\w+`, nil},
+	}
+	for _, test := range tests {
+		r, err := CompileRegexp(test.expr)
+		if err != nil {
+			t.Errorf("CompileRegexp(%q) returned error %v", test.expr, err)
+			continue
+		}
+		if literals := r.Literals(); !reflect.DeepEqual(literals, test.literals) {
+			t.Errorf("%q.Literals() returned %q", test.expr, literals)
+		}
+	}
+}
+
+func TestCompileRegexps(t *testing.T) {
+	r, err := CompileRegexps([]string{`(a)(b)?c`, `(?i)X+`, `\d`})
+	if err != nil {
+		t.Fatalf("CompileRegexps returned error %v", err)
+	}
+	matches := r.FindAll([]byte("1 ac xX abc"), -1)
+	expected := []base.Match{
+		{StartIndex: 0, EndIndex: 1, Pattern: 2},
+		{StartIndex: 2, EndIndex: 4, Pattern: 0},
+		{StartIndex: 5, EndIndex: 7, Pattern: 1},
+		{StartIndex: 8, EndIndex: 11, Pattern: 0},
+	}
+	if !reflect.DeepEqual(matches, expected) {
+		t.Errorf("FindAll returned %v", matches)
+	}
+	if r.Literals() != nil {
+		t.Errorf("Literals returned %q", r.Literals())
+	}
+
+	r, err = CompileRegexps([]string{`foo`, `foobar`, `bar|foobar`})
+	if err != nil {
+		t.Fatalf("CompileRegexps returned error %v", err)
+	}
+	matches = r.FindAll([]byte("foobar foo"), -1)
+	expected = []base.Match{
+		{StartIndex: 0, EndIndex: 6, Pattern: 1},
+		{StartIndex: 7, EndIndex: 10, Pattern: 0},
+	}
+	if !reflect.DeepEqual(matches, expected) {
+		t.Errorf("FindAll returned %v", matches)
+	}
+	if replaced := r.Replace(nil, "[$0]", []byte("foobar foo"), matches); string(replaced) != "[foobar] [foo]" {
+		t.Errorf("Replace returned %q", replaced)
+	}
+
+	_, err = CompileRegexps([]string{`a`, `(`})
+	if err == nil {
+		t.Error("CompileRegexps returned no error")
+	}
+
+	r, err = CompileRegexps([]string{`abc`})
+	if err != nil {
+		t.Fatalf("CompileRegexps returned error %v", err)
+	}
+	if !reflect.DeepEqual(r.Literals(), []string{"abc"}) {
+		t.Errorf("Literals returned %q", r.Literals())
+	}
+}
+
+func TestRegexp_Replace(t *testing.T) {
+	r, err := CompileRegexp(`(?P<key>\w+)=(\d+)`)
+	if err != nil {
+		t.Fatalf("CompileRegexp returned error %v", err)
+	}
+	src := []byte("a=1, b=22, c")
+	replaced := r.Replace(nil, "${key}:$2", src, r.FindAll(src, -1))
+	if string(replaced) != "a:1, b:22, c" {
+		t.Errorf("Replace returned %q", replaced)
+	}
+	replaced = r.Replace(nil, "$1", src, r.FindAll(src, 1))
+	if string(replaced) != "a, b=22, c" {
+		t.Errorf("Replace returned %q", replaced)
+	}
+
+	r, err = CompileRegexps([]string{`(a)(b)?c`, `x(\d)`})
+	if err != nil {
+		t.Fatalf("CompileRegexps returned error %v", err)
+	}
+	src = []byte("ac x5 abc")
+	replaced = r.Replace([]byte(">"), "[$1]", src, r.FindAll(src, -1))
+	if string(replaced) != ">[a] [5] [a]" {
+		t.Errorf("Replace returned %q", replaced)
+	}
+}
@@ -0,0 +1,550 @@
+package reader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+// Separates path of an archive from path of its member, e.g. release.zip!/lib/config.yml
+const ArchiveSeparator = "!/"
+
+// Number of recently read archives that are kept open
+const archiveCacheSize = 8
+
+var (
+	ErrArchiveTooLarge  = errors.New("archive is too large to read")
+	ErrNoArchiveMember  = errors.New("archive has no such member")
+	ErrArchiveMemberDir = errors.New("archive member is a directory")
+)
+
+type archiveFormat int
+
+const (
+	notArchive archiveFormat = iota
+	zipArchive
+	tarArchive
+	tarGzipArchive
+	tarBzip2Archive
+)
+
+// Reader that exposes zip and tar archives as directories.
+// Members of an archive have paths that are joined to the path of the archive by ArchiveSeparator.
+// Archives are detected by extension and are read as files when they cannot be read as archives
+type Archive struct {
+	reader     base.Reader
+	maxNesting int   // max number of archives a member can be in
+	maxSize    int64 // max size of an archive that has to be read to memory. Zero means no limit
+	mu         sync.Mutex
+	cache      []*archiveIndex // recently read archives. Most recent is the last one
+}
+
+type ArchiveOption func(*Archive)
+
+// Sets max number of archives a member can be in. Archives deeper than that are read as files.
+// Default is 1 that means archives inside of archives are not expanded
+func WithArchiveMaxNesting(maxNesting int) ArchiveOption {
+	return func(a *Archive) {
+		a.maxNesting = max(maxNesting, 0)
+	}
+}
+
+// Sets max size of an archive that has to be read to memory.
+// Those are archives inside of archives, compressed tar archives and ones that are not files on disk
+func WithArchiveMaxSize(maxSize int64) ArchiveOption {
+	return func(a *Archive) {
+		a.maxSize = max(maxSize, 0)
+	}
+}
+
+func NewArchive(reader base.Reader, options ...ArchiveOption) base.Reader {
+	archive := Archive{reader: reader, maxNesting: 1}
+	for _, option := range options {
+		option(&archive)
+	}
+	return &archive
+}
+
+func (a *Archive) OpenFile(fileEntry base.DirEntry) (io.ReadCloser, error) {
+	archivePath, memberPath, ok := splitArchivePath(fileEntry.Path)
+	if !ok {
+		return a.reader.OpenFile(fileEntry)
+	}
+	index, err := a.acquire(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	file, err := index.open(memberPath)
+	if err != nil {
+		a.release(index)
+		return nil, err
+	}
+	return &archiveMemberReader{file, a, index}, nil
+}
+
+func (a *Archive) ReadDir(dirEntry base.DirEntry) (base.Iterator[base.DirEntry], error) {
+	archivePath, memberPath, ok := splitArchivePath(dirEntry.Path)
+	if ok {
+		index, err := a.acquire(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		defer a.release(index)
+		if _, isDir := index.dirs[memberPath]; isDir {
+			return a.newMembersIterator(dirEntry, dirEntry.Path+"/", index.children(memberPath)), nil
+		}
+	} else if archiveFormatOf(dirEntry.Path) == notArchive {
+		iter, err := a.reader.ReadDir(dirEntry)
+		return a.newEntriesIterator(iter), err
+	} else if info, err := a.reader.ReadRootEntry(dirEntry.Path, dirEntry.Depth); err == nil && info.IsDir {
+		// Directory has a name of an archive
+		iter, err := a.reader.ReadDir(dirEntry)
+		return a.newEntriesIterator(iter), err
+	}
+	index, err := a.acquire(dirEntry.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer a.release(index)
+	return a.newMembersIterator(dirEntry, dirEntry.Path+ArchiveSeparator, index.children("")), nil
+}
+
+func (a *Archive) ReadRootEntry(name string, depth int) (base.DirEntry, error) {
+	archivePath, memberPath, ok := splitArchivePath(name)
+	if !ok {
+		entry, err := a.reader.ReadRootEntry(name, depth)
+		if err == nil && !entry.IsDir {
+			entry.IsDir = a.expandable(entry.Path)
+		}
+		return entry, err
+	}
+	index, err := a.acquire(archivePath)
+	if err != nil {
+		return base.DirEntry{}, err
+	}
+	defer a.release(index)
+	member, ok := index.members[memberPath]
+	if !ok {
+		return base.DirEntry{}, ErrNoArchiveMember
+	}
+	return a.memberEntry(name, depth, member), nil
+}
+
+// Checks if a file is an archive that can be read as a directory.
+// Archive that is too large is still a directory, so reading it fails and is reported instead of scanning it as a file
+func (a *Archive) expandable(filePath string) bool {
+	if archiveFormatOf(filePath) == notArchive || archiveNesting(filePath) >= a.maxNesting {
+		return false
+	}
+	index, err := a.acquire(filePath)
+	if err != nil {
+		return errors.Is(err, ErrArchiveTooLarge)
+	}
+	a.release(index)
+	return true
+}
+
+func archiveFormatOf(filePath string) archiveFormat {
+	name := strings.ToLower(path.Base(filePath))
+	switch {
+	case strings.HasSuffix(name, ".zip"), strings.HasSuffix(name, ".jar"), strings.HasSuffix(name, ".war"), strings.HasSuffix(name, ".ear"):
+		return zipArchive
+	case strings.HasSuffix(name, ".tar"):
+		return tarArchive
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return tarGzipArchive
+	case strings.HasSuffix(name, ".tar.bz2"), strings.HasSuffix(name, ".tbz2"):
+		return tarBzip2Archive
+	}
+	return notArchive
+}
+
+func (a *Archive) memberEntry(entryPath string, depth int, member archiveMember) base.DirEntry {
+	entry := base.DirEntry{Path: entryPath, Depth: depth, IsDir: member.isDir, Size: member.size, ModTime: member.modTime}
+	if !entry.IsDir {
+		entry.IsDir = a.expandable(entryPath)
+	}
+	return entry
+}
+
+// Returns index of an archive from cache or reads it. Index must be released after use
+func (a *Archive) acquire(archivePath string) (*archiveIndex, error) {
+	a.mu.Lock()
+	if index := a.cached(archivePath); index != nil {
+		a.mu.Unlock()
+		return index, nil
+	}
+	a.mu.Unlock()
+
+	index, err := a.readIndex(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// Other goroutine could read the same archive meanwhile
+	if cached := a.cached(archivePath); cached != nil {
+		index.close()
+		return cached, nil
+	}
+	index.users++
+	a.cache = append(a.cache, index)
+	if len(a.cache) > archiveCacheSize {
+		evicted := a.cache[0]
+		a.cache = slices.Delete(a.cache, 0, 1)
+		evicted.evicted = true
+		if evicted.users == 0 {
+			evicted.close()
+		}
+	}
+	return index, nil
+}
+
+// Finds index in cache and marks it as used. Must be called with the lock held
+func (a *Archive) cached(archivePath string) *archiveIndex {
+	for i, index := range a.cache {
+		if index.path == archivePath {
+			a.cache = append(slices.Delete(a.cache, i, i+1), index)
+			index.users++
+			return index
+		}
+	}
+	return nil
+}
+
+func (a *Archive) release(index *archiveIndex) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	index.users--
+	if index.evicted && index.users == 0 {
+		index.close()
+	}
+}
+
+// Reads list of members of an archive
+func (a *Archive) readIndex(archivePath string) (*archiveIndex, error) {
+	format := archiveFormatOf(archivePath)
+	if format == notArchive {
+		return nil, ErrNoArchiveMember
+	}
+	file, err := a.OpenFile(base.DirEntry{Path: archivePath})
+	if err != nil {
+		return nil, err
+	}
+
+	var source io.ReaderAt
+	var size int64
+	var closer io.Closer
+	switch format {
+	case tarGzipArchive, tarBzip2Archive:
+		var decompressed io.Reader
+		if format == tarGzipArchive {
+			decompressed, err = gzip.NewReader(file)
+		} else {
+			decompressed = bzip2.NewReader(file)
+		}
+		if err == nil {
+			source, size, err = a.readAll(decompressed)
+		}
+		file.Close()
+	default:
+		if diskFile, ok := file.(interface {
+			io.ReaderAt
+			Stat() (fs.FileInfo, error)
+		}); ok {
+			var info fs.FileInfo
+			info, err = diskFile.Stat()
+			if err == nil {
+				source, size, closer = diskFile, info.Size(), file
+			} else {
+				file.Close()
+			}
+		} else {
+			source, size, err = a.readAll(file)
+			file.Close()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	index := &archiveIndex{path: archivePath, source: source, closer: closer}
+	if format == zipArchive {
+		err = index.readZip(size)
+	} else {
+		err = index.readTar(size)
+	}
+	if err != nil {
+		index.close()
+		return nil, err
+	}
+	return index, nil
+}
+
+// Reads archive content to memory
+func (a *Archive) readAll(reader io.Reader) (io.ReaderAt, int64, error) {
+	if a.maxSize > 0 {
+		reader = io.LimitReader(reader, a.maxSize+1)
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, 0, err
+	}
+	if a.maxSize > 0 && int64(len(content)) > a.maxSize {
+		return nil, 0, ErrArchiveTooLarge
+	}
+	return bytes.NewReader(content), int64(len(content)), nil
+}
+
+func (a *Archive) newEntriesIterator(entries base.Iterator[base.DirEntry]) base.Iterator[base.DirEntry] {
+	if entries == nil {
+		return nil
+	}
+	return &archiveIterator{archive: a, entries: entries}
+}
+
+func (a *Archive) newMembersIterator(dirEntry base.DirEntry, prefix string, members []namedMember) base.Iterator[base.DirEntry] {
+	return &archiveIterator{archive: a, members: members, prefix: prefix, depth: dirEntry.Depth + 1}
+}
+
+// Splits path of an archive member into path of the archive and path of the member in it.
+// Path of the archive may be a path of a member of another archive
+func splitArchivePath(filePath string) (string, string, bool) {
+	for end := len(filePath); end > 0; {
+		separator := strings.LastIndex(filePath[:end], ArchiveSeparator)
+		if separator < 0 {
+			break
+		}
+		archivePath := filePath[:separator]
+		if archiveFormatOf(archivePath) != notArchive {
+			return archivePath, filePath[separator+len(ArchiveSeparator):], true
+		}
+		end = separator
+	}
+	return "", "", false
+}
+
+// Number of archives a path is in
+func archiveNesting(filePath string) int {
+	nesting := 0
+	for archivePath, _, ok := splitArchivePath(filePath); ok; archivePath, _, ok = splitArchivePath(archivePath) {
+		nesting++
+	}
+	return nesting
+}
+
+type namedMember struct {
+	name string
+	archiveMember
+}
+
+type archiveMember struct {
+	isDir   bool
+	size    int64
+	modTime time.Time
+	zipFile *zip.File // member of zip archive
+	offset  int64     // offset of content of tar archive member
+}
+
+// List of members of an archive
+type archiveIndex struct {
+	path    string
+	source  io.ReaderAt
+	closer  io.Closer                // closes source when it is a file
+	members map[string]archiveMember // members by their paths in the archive
+	dirs    map[string][]string      // sorted names of children of directories. Root has empty path
+	users   int                      // number of acquired references
+	evicted bool                     // whether index is removed from cache
+}
+
+func (i *archiveIndex) readZip(size int64) error {
+	zipReader, err := zip.NewReader(i.source, size)
+	if err != nil {
+		return err
+	}
+	i.init()
+	for _, zipFile := range zipReader.File {
+		info := zipFile.FileInfo()
+		if info.IsDir() {
+			i.add(zipFile.Name, archiveMember{isDir: true, modTime: info.ModTime()})
+		} else if info.Mode().IsRegular() {
+			i.add(zipFile.Name, archiveMember{size: info.Size(), modTime: info.ModTime(), zipFile: zipFile})
+		}
+	}
+	return nil
+}
+
+func (i *archiveIndex) readTar(size int64) error {
+	counter := &countingReader{reader: io.NewSectionReader(i.source, 0, size)}
+	tarReader := tar.NewReader(counter)
+	i.init()
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		info := header.FileInfo()
+		if info.IsDir() {
+			i.add(header.Name, archiveMember{isDir: true, modTime: info.ModTime()})
+		} else if info.Mode().IsRegular() {
+			// Header is read up to the content
+			i.add(header.Name, archiveMember{size: info.Size(), modTime: info.ModTime(), offset: counter.count})
+		}
+	}
+}
+
+func (i *archiveIndex) init() {
+	i.members = make(map[string]archiveMember)
+	i.dirs = map[string][]string{"": nil}
+}
+
+// Adds a member and its parent directories that archive may have no entries for
+func (i *archiveIndex) add(name string, member archiveMember) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return
+	}
+	if existing, ok := i.members[name]; ok && existing.isDir && !member.isDir {
+		return
+	}
+	if _, ok := i.members[name]; !ok {
+		parent := path.Dir(name)
+		if parent == "." {
+			parent = ""
+		} else if _, ok := i.dirs[parent]; !ok {
+			i.add(parent, archiveMember{isDir: true, modTime: member.modTime})
+		}
+		children := i.dirs[parent]
+		position, _ := slices.BinarySearch(children, path.Base(name))
+		i.dirs[parent] = slices.Insert(children, position, path.Base(name))
+	}
+	i.members[name] = member
+	if _, ok := i.dirs[name]; member.isDir && !ok {
+		i.dirs[name] = nil
+	}
+}
+
+// Returns members of a directory in order of their names
+func (i *archiveIndex) children(dirPath string) []namedMember {
+	prefix := ""
+	if dirPath != "" {
+		prefix = dirPath + "/"
+	}
+	names := i.dirs[dirPath]
+	members := make([]namedMember, 0, len(names))
+	for _, name := range names {
+		members = append(members, namedMember{name, i.members[prefix+name]})
+	}
+	return members
+}
+
+func (i *archiveIndex) open(memberPath string) (io.ReadCloser, error) {
+	member, ok := i.members[memberPath]
+	if !ok {
+		return nil, ErrNoArchiveMember
+	}
+	if member.isDir {
+		return nil, ErrArchiveMemberDir
+	}
+	if member.zipFile != nil {
+		return member.zipFile.Open()
+	}
+	return io.NopCloser(io.NewSectionReader(i.source, member.offset, member.size)), nil
+}
+
+func (i *archiveIndex) close() {
+	if i.closer != nil {
+		i.closer.Close()
+	}
+}
+
+// Reads member of an archive and releases the archive on close
+type archiveMemberReader struct {
+	io.ReadCloser
+	archive *Archive
+	index   *archiveIndex
+}
+
+func (r *archiveMemberReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.archive.release(r.index)
+	return err
+}
+
+// Counts bytes that are read
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// Iterates entries of a directory or members of an archive directory.
+// Archives become directories
+type archiveIterator struct {
+	archive *Archive
+	entries base.Iterator[base.DirEntry] // entries of a directory that is not in archive
+	members []namedMember                // members of an archive directory
+	prefix  string                       // path of an archive directory with a separator
+	depth   int                          // depth of members
+	value   base.DirEntry
+}
+
+func (i *archiveIterator) Next() bool {
+	if i.entries != nil {
+		if !i.entries.Next() {
+			return false
+		}
+		i.value = i.entries.Value()
+		if i.entries.Err() == nil && !i.value.IsDir {
+			i.value.IsDir = i.archive.expandable(i.value.Path)
+		}
+		return true
+	}
+	if len(i.members) == 0 {
+		return false
+	}
+	member := i.members[0]
+	i.members = i.members[1:]
+	i.value = i.archive.memberEntry(i.prefix+member.name, i.depth, member.archiveMember)
+	return true
+}
+
+func (i *archiveIterator) Value() base.DirEntry {
+	return i.value
+}
+
+func (i *archiveIterator) Err() error {
+	if i.entries != nil {
+		return i.entries.Err()
+	}
+	return nil
+}
+
+// Closes iterator of the wrapped reader if it holds resources
+func (i *archiveIterator) Close() error {
+	if closer, ok := i.entries.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package reader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+var archiveTestModTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// Files of test archives in order they are written
+var archiveTestFiles = [][2]string{
+	{"top.txt", "hello top"},
+	{"lib/config.yml", "key: hello"},
+	{"lib/deep/readme.md", "deep"},
+}
+
+func zipForTest(t *testing.T, files [][2]string) string {
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for _, file := range files {
+		fileWriter, err := writer.CreateHeader(&zip.FileHeader{Name: file[0], Modified: archiveTestModTime})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fileWriter.Write([]byte(file[1]))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.String()
+}
+
+func tarGzipForTest(t *testing.T, files [][2]string) string {
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	writer := tar.NewWriter(gzipWriter)
+	for _, file := range files {
+		err := writer.WriteHeader(&tar.Header{Name: file[0], Mode: 0644, Size: int64(len(file[1])), ModTime: archiveTestModTime, Typeflag: tar.TypeReg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.Write([]byte(file[1]))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.String()
+}
+
+func archiveMockEntries(t *testing.T) MockEntries {
+	zipContent := zipForTest(t, archiveTestFiles)
+	tarContent := tarGzipForTest(t, archiveTestFiles)
+	outerContent := zipForTest(t, [][2]string{{"inner.jar", zipContent}})
+	notZip := "not a zip"
+	return MockEntries{
+		"dir":                {},
+		"dir/release.zip":    {Content: &zipContent, ModTime: archiveTestModTime},
+		"dir/release.tar.gz": {Content: &tarContent, ModTime: archiveTestModTime},
+		"dir/outer.zip":      {Content: &outerContent, ModTime: archiveTestModTime},
+		"dir/broken.zip":     {Content: &notZip, ModTime: archiveTestModTime},
+	}
+}
+
+func TestSplitArchivePath(t *testing.T) {
+	tests := []struct {
+		path        string
+		wantArchive string
+		wantMember  string
+		wantOk      bool
+	}{
+		{"dir/file.txt", "", "", false},
+		{"dir/release.zip", "", "", false},
+		{"dir/release.zip!/lib/config.yml", "dir/release.zip", "lib/config.yml", true},
+		{"dir/outer.zip!/inner.jar!/top.txt", "dir/outer.zip!/inner.jar", "top.txt", true},
+		{"dir/outer.zip!/wow!/top.txt", "dir/outer.zip", "wow!/top.txt", true},
+		{"dir/wow!/top.txt", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			archivePath, memberPath, ok := splitArchivePath(tt.path)
+			if archivePath != tt.wantArchive || memberPath != tt.wantMember || ok != tt.wantOk {
+				t.Errorf("splitArchivePath() = %q, %q, %v, want %q, %q, %v", archivePath, memberPath, ok, tt.wantArchive, tt.wantMember, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestArchive_ReadDir(t *testing.T) {
+	entries := archiveMockEntries(t)
+	size := func(path string) int64 {
+		return int64(len(*entries[path].Content))
+	}
+	tests := []struct {
+		name       string
+		maxNesting int
+		dir        base.DirEntry
+		want       []base.DirEntry
+	}{
+		{
+			"Archives become directories",
+			1,
+			base.DirEntry{Path: "dir", Depth: 0, IsDir: true},
+			[]base.DirEntry{
+				{Path: "dir/broken.zip", Depth: 1, Size: 9, ModTime: archiveTestModTime},
+				{Path: "dir/outer.zip", Depth: 1, IsDir: true, Size: size("dir/outer.zip"), ModTime: archiveTestModTime},
+				{Path: "dir/release.tar.gz", Depth: 1, IsDir: true, Size: size("dir/release.tar.gz"), ModTime: archiveTestModTime},
+				{Path: "dir/release.zip", Depth: 1, IsDir: true, Size: size("dir/release.zip"), ModTime: archiveTestModTime},
+			},
+		},
+		{
+			"Zip root",
+			1,
+			base.DirEntry{Path: "dir/release.zip", Depth: 1, IsDir: true},
+			[]base.DirEntry{
+				{Path: "dir/release.zip!/lib", Depth: 2, IsDir: true, ModTime: archiveTestModTime},
+				{Path: "dir/release.zip!/top.txt", Depth: 2, Size: 9, ModTime: archiveTestModTime},
+			},
+		},
+		{
+			"Tar directory",
+			1,
+			base.DirEntry{Path: "dir/release.tar.gz!/lib", Depth: 2, IsDir: true},
+			[]base.DirEntry{
+				{Path: "dir/release.tar.gz!/lib/config.yml", Depth: 3, Size: 10, ModTime: archiveTestModTime},
+				{Path: "dir/release.tar.gz!/lib/deep", Depth: 3, IsDir: true, ModTime: archiveTestModTime},
+			},
+		},
+		{
+			"Nested archive is a file",
+			1,
+			base.DirEntry{Path: "dir/outer.zip", Depth: 1, IsDir: true},
+			[]base.DirEntry{
+				{Path: "dir/outer.zip!/inner.jar", Depth: 2, Size: size("dir/release.zip"), ModTime: archiveTestModTime},
+			},
+		},
+		{
+			"Nested archive is a directory",
+			2,
+			base.DirEntry{Path: "dir/outer.zip", Depth: 1, IsDir: true},
+			[]base.DirEntry{
+				{Path: "dir/outer.zip!/inner.jar", Depth: 2, IsDir: true, Size: size("dir/release.zip"), ModTime: archiveTestModTime},
+			},
+		},
+		{
+			"Nested archive root",
+			2,
+			base.DirEntry{Path: "dir/outer.zip!/inner.jar", Depth: 2, IsDir: true},
+			[]base.DirEntry{
+				{Path: "dir/outer.zip!/inner.jar!/lib", Depth: 3, IsDir: true, ModTime: archiveTestModTime},
+				{Path: "dir/outer.zip!/inner.jar!/top.txt", Depth: 3, Size: 9, ModTime: archiveTestModTime},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewArchive(NewMockReader(entries), WithArchiveMaxNesting(tt.maxNesting))
+			iter, err := r.ReadDir(tt.dir)
+			if err != nil {
+				t.Fatalf("ReadDir error: %v", err)
+			}
+			var got []base.DirEntry
+			for iter.Next() {
+				entry := iter.Value()
+				entry.ModTime = entry.ModTime.UTC()
+				got = append(got, entry)
+			}
+			if iter.Err() != nil {
+				t.Errorf("Iterator error: %v", iter.Err())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadDir() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArchive_OpenFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxNesting int
+		maxSize    int64
+		path       string
+		want       string
+		wantErr    error
+	}{
+		{"File", 1, 0, "dir/broken.zip", "not a zip", nil},
+		{"Zip member", 1, 0, "dir/release.zip!/lib/config.yml", "key: hello", nil},
+		{"Tar member", 1, 0, "dir/release.tar.gz!/lib/deep/readme.md", "deep", nil},
+		{"Nested member", 2, 0, "dir/outer.zip!/inner.jar!/top.txt", "hello top", nil},
+		{"Missing member", 1, 0, "dir/release.zip!/missing.txt", "", ErrNoArchiveMember},
+		{"Directory member", 1, 0, "dir/release.zip!/lib", "", ErrArchiveMemberDir},
+		{"Too large", 1, 10, "dir/release.tar.gz!/top.txt", "", ErrArchiveTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewArchive(NewMockReader(archiveMockEntries(t)), WithArchiveMaxNesting(tt.maxNesting), WithArchiveMaxSize(tt.maxSize))
+			file, err := r.OpenFile(base.DirEntry{Path: tt.path})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("OpenFile error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			content, err := io.ReadAll(file)
+			file.Close()
+			if err != nil {
+				t.Errorf("ReadAll error: %v", err)
+			}
+			if string(content) != tt.want {
+				t.Errorf("ReadAll = %q, want %q", content, tt.want)
+			}
+		})
+	}
+}
+
+func TestArchive_ReadRootEntry(t *testing.T) {
+	entries := archiveMockEntries(t)
+	tests := []struct {
+		name    string
+		path    string
+		want    base.DirEntry
+		wantErr error
+	}{
+		{"Archive", "dir/release.zip", base.DirEntry{Path: "dir/release.zip", Depth: 1, IsDir: true, Size: int64(len(*entries["dir/release.zip"].Content)), ModTime: archiveTestModTime}, nil},
+		{"Broken archive", "dir/broken.zip", base.DirEntry{Path: "dir/broken.zip", Depth: 1, Size: 9, ModTime: archiveTestModTime}, nil},
+		{"Member directory", "dir/release.zip!/lib", base.DirEntry{Path: "dir/release.zip!/lib", Depth: 1, IsDir: true, ModTime: archiveTestModTime}, nil},
+		{"Member file", "dir/release.tar.gz!/top.txt", base.DirEntry{Path: "dir/release.tar.gz!/top.txt", Depth: 1, Size: 9, ModTime: archiveTestModTime}, nil},
+		{"Missing member", "dir/release.zip!/missing", base.DirEntry{}, ErrNoArchiveMember},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewArchive(NewMockReader(entries))
+			got, err := r.ReadRootEntry(tt.path, 1)
+			got.ModTime = got.ModTime.UTC()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ReadRootEntry error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadRootEntry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+var ErrDecompressedTooLarge = errors.New("decompressed file is too large")
+
+// Reader that decompresses content of gzip, bzip2, zlib and flate files.
+// Format is detected by magic bytes. Flate has none so it is detected by .deflate extension.
+// Other files are read as is
+type Decompress struct {
+	reader  base.Reader
+	maxSize int64 // max number of decompressed bytes. Zero means no limit
+}
+
+type DecompressOption func(*Decompress)
+
+// Sets max number of decompressed bytes of a file.
+// Reading more than that fails with ErrDecompressedTooLarge
+func WithDecompressMaxSize(maxSize int64) DecompressOption {
+	return func(d *Decompress) {
+		d.maxSize = max(maxSize, 0)
+	}
+}
+
+func NewDecompress(reader base.Reader, options ...DecompressOption) base.Reader {
+	decompress := Decompress{reader: reader}
+	for _, option := range options {
+		option(&decompress)
+	}
+	return &decompress
+}
+
+func (d *Decompress) OpenFile(fileEntry base.DirEntry) (io.ReadCloser, error) {
+	file, err := d.reader.OpenFile(fileEntry)
+	if err != nil {
+		return nil, err
+	}
+	bufferedFile := bufio.NewReader(file)
+	magic, _ := bufferedFile.Peek(4)
+	var decompressed io.Reader
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		decompressed, err = gzip.NewReader(bufferedFile)
+	case len(magic) == 4 && bytes.HasPrefix(magic, []byte("BZh")) && magic[3] >= '1' && magic[3] <= '9':
+		decompressed = bzip2.NewReader(bufferedFile)
+	case isZlibHeader(magic):
+		decompressed, err = zlib.NewReader(bufferedFile)
+	case strings.EqualFold(filepath.Ext(fileEntry.Path), ".deflate"):
+		decompressed = flate.NewReader(bufferedFile)
+	default:
+		return &decompressReader{bufferedFile, file, nil}, nil
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if d.maxSize > 0 {
+		decompressed = &sizeLimitReader{decompressed, d.maxSize}
+	}
+	return &decompressReader{decompressed, file, decompressed}, nil
+}
+
+func (d *Decompress) ReadDir(dirEntry base.DirEntry) (base.Iterator[base.DirEntry], error) {
+	return d.reader.ReadDir(dirEntry)
+}
+
+func (d *Decompress) ReadRootEntry(name string, depth int) (base.DirEntry, error) {
+	return d.reader.ReadRootEntry(name, depth)
+}
+
+// Checks if a zlib header that is written with one of standard compression levels starts a block.
+// Header that may be a start of a text is not accepted
+func isZlibHeader(magic []byte) bool {
+	if len(magic) < 2 || magic[0] != 0x78 {
+		return false
+	}
+	return magic[1] == 0x01 || magic[1] == 0x9c || magic[1] == 0xda
+}
+
+// Reads decompressed content and closes both decompressor and file
+type decompressReader struct {
+	io.Reader
+	file         io.Closer
+	decompressor io.Reader
+}
+
+func (r *decompressReader) Close() error {
+	var err error
+	if closer, ok := r.decompressor.(io.Closer); ok {
+		err = closer.Close()
+	}
+	if fileErr := r.file.Close(); fileErr != nil {
+		err = fileErr
+	}
+	return err
+}
+
+// Fails when more than a limit of bytes can be read
+type sizeLimitReader struct {
+	reader io.Reader
+	left   int64
+}
+
+func (r *sizeLimitReader) Read(p []byte) (int, error) {
+	if r.left < 0 {
+		return 0, ErrDecompressedTooLarge
+	}
+	// One extra byte is read to know if there is more than a limit
+	if int64(len(p)) > r.left+1 {
+		p = p[:r.left+1]
+	}
+	n, err := r.reader.Read(p)
+	r.left -= int64(n)
+	if r.left < 0 {
+		return n + int(r.left), ErrDecompressedTooLarge
+	}
+	return n, err
+}
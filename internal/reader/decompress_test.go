@@ -0,0 +1,93 @@
+package reader
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+const decompressTestContent = "this is\nfor test"
+
+// Output of bzip2 for decompressTestContent. Standard library has no bzip2 compressor
+const decompressTestBzip2 = "\x42\x5a\x68\x39\x31\x41\x59\x26\x53\x59\xab\xed\xca\x25\x00\x00\x07\x51\x80\x00\x10\x40\x00\x03\x60\x9c\x00\x20\x00\x31\x00\x30\x20\x03\x6a\x26\x45\x45\xf7\x03\x19\xa7\x8b\xb9\x22\x9c\x28\x48\x55\xf6\xe5\x12\x80"
+
+func compressForTest(t *testing.T, newWriter func(io.Writer) io.WriteCloser) *string {
+	var buffer bytes.Buffer
+	writer := newWriter(&buffer)
+	if _, err := writer.Write([]byte(decompressTestContent)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	content := buffer.String()
+	return &content
+}
+
+func TestDecompress_OpenFile(t *testing.T) {
+	plain := decompressTestContent
+	bzip2Content := decompressTestBzip2
+	zlibLike := "x^ is a plain text"
+	entries := MockEntries{
+		"plain.txt": {Content: &plain},
+		"log.gz": {Content: compressForTest(t, func(w io.Writer) io.WriteCloser {
+			return gzip.NewWriter(w)
+		})},
+		"log.bz2": {Content: &bzip2Content},
+		"log.zlib": {Content: compressForTest(t, func(w io.Writer) io.WriteCloser {
+			return zlib.NewWriter(w)
+		})},
+		"log.deflate": {Content: compressForTest(t, func(w io.Writer) io.WriteCloser {
+			writer, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return writer
+		})},
+		// Format is detected by content not by extension
+		"log.txt": {Content: compressForTest(t, func(w io.Writer) io.WriteCloser {
+			return gzip.NewWriter(w)
+		})},
+		"zlib.txt": {Content: &zlibLike},
+	}
+	tests := []struct {
+		name    string
+		path    string
+		maxSize int64
+		want    string
+		wantErr error
+	}{
+		{"Plain", "plain.txt", 0, decompressTestContent, nil},
+		{"Gzip", "log.gz", 0, decompressTestContent, nil},
+		{"Bzip2", "log.bz2", 0, decompressTestContent, nil},
+		{"Zlib", "log.zlib", 0, decompressTestContent, nil},
+		{"Flate", "log.deflate", 0, decompressTestContent, nil},
+		{"Gzip without extension", "log.txt", 0, decompressTestContent, nil},
+		{"Text like zlib header", "zlib.txt", 0, zlibLike, nil},
+		{"Max size", "log.gz", int64(len(decompressTestContent)), decompressTestContent, nil},
+		{"Too large", "log.gz", 4, "this", ErrDecompressedTooLarge},
+		{"Max size of plain", "plain.txt", 4, decompressTestContent, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewDecompress(NewMockReader(entries), WithDecompressMaxSize(tt.maxSize))
+			file, err := r.OpenFile(base.DirEntry{Path: tt.path})
+			if err != nil {
+				t.Fatalf("OpenFile error: %v", err)
+			}
+			content, err := io.ReadAll(file)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ReadAll error = %v, want %v", err, tt.wantErr)
+			}
+			if string(content) != tt.want {
+				t.Errorf("ReadAll = %q, want %q", content, tt.want)
+			}
+			if err := file.Close(); err != nil {
+				t.Errorf("Close error: %v", err)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package reader
 
 import (
+	"errors"
 	"io"
 	"io/fs"
 	"os"
@@ -9,58 +10,158 @@ import (
 	"github.com/pi-kei/mgrep/internal/base"
 )
 
-type FileSystem struct{}
+var (
+	ErrBrokenLink = errors.New("broken symbolic link")
+	ErrLinkLoop   = errors.New("symbolic link to a parent directory")
+)
+
+// Number of entries read at once from a directory in unsorted mode
+const DefaultReadDirBatchSize = 1024
+
+type FileSystem struct {
+	follow    bool // whether symbolic links are resolved
+	unsorted  bool // whether directories are read in batches in the order of the file system
+	batchSize int  // number of entries read at once in unsorted mode
+}
+
+type FileSystemOption func(*FileSystem)
 
-func NewFileSystem() base.Reader {
-	return &FileSystem{}
+// Makes reader resolve symbolic links so linked directories are read too.
+// Link to a directory that contains the link is an entry that fails with ErrLinkLoop, so it is skipped with a warning
+func WithFollow() FileSystemOption {
+	return func(fs *FileSystem) {
+		fs.follow = true
+	}
+}
+
+// Makes reader read directories in batches in the order of the file system instead of reading and sorting all entries first.
+// Memory does not grow with the size of a directory and the first entries come sooner
+func WithUnsorted() FileSystemOption {
+	return func(fs *FileSystem) {
+		fs.unsorted = true
+	}
+}
+
+func NewFileSystem(options ...FileSystemOption) base.Reader {
+	fileSystem := FileSystem{batchSize: DefaultReadDirBatchSize}
+	for _, option := range options {
+		option(&fileSystem)
+	}
+	return &fileSystem
 }
 
 func (fs *FileSystem) OpenFile(fileEntry base.DirEntry) (io.ReadCloser, error) {
-	return os.Open(fileEntry.Path)
+	file, err := os.Open(fileEntry.Path)
+	if err != nil {
+		if info, linkErr := os.Lstat(fileEntry.Path); errors.Is(err, os.ErrNotExist) && linkErr == nil && info.Mode()&os.ModeSymlink != 0 {
+			return nil, &os.PathError{Op: "open", Path: fileEntry.Path, Err: ErrBrokenLink}
+		}
+		return nil, err
+	}
+	return file, nil
 }
 
 func (fs *FileSystem) ReadDir(dirEntry base.DirEntry) (base.Iterator[base.DirEntry], error) {
+	if fs.unsorted {
+		return fs.readDirBatches(dirEntry)
+	}
 	fsDirEntries, err := os.ReadDir(dirEntry.Path)
-	return newIterator(dirEntry.Path, dirEntry.Depth+1, fsDirEntries), err
+	iter := newIterator(dirEntry.Path, dirEntry.Depth+1, fsDirEntries, filepath.Join)
+	if fs.follow {
+		iter.resolve = followLink
+	}
+	return iter, err
 }
 
+// Returns iterator that reads entries of an open directory in batches. Directory is closed at the end or by Close
+func (fs *FileSystem) readDirBatches(dirEntry base.DirEntry) (base.Iterator[base.DirEntry], error) {
+	file, err := os.Open(dirEntry.Path)
+	if err != nil {
+		return nil, err
+	}
+	iter := newIterator(dirEntry.Path, dirEntry.Depth+1, nil, filepath.Join)
+	iter.more = func() ([]os.DirEntry, error) {
+		return file.ReadDir(fs.batchSize)
+	}
+	iter.closer = file
+	if fs.follow {
+		iter.resolve = followLink
+	}
+	return iter, nil
+}
+
+// Root is resolved when it is a symbolic link even if links are not followed, the same way as command line arguments of grep
 func (fs *FileSystem) ReadRootEntry(name string, depth int) (base.DirEntry, error) {
-	info, err := os.Lstat(name)
+	info, err := os.Stat(name)
 	if err != nil {
 		return base.DirEntry{}, err
 	}
-	return base.DirEntry{Path: name, Depth: depth, IsDir: info.IsDir(), Size: info.Size(), ModTime: info.ModTime()}, nil
+	return base.DirEntry{Path: name, Depth: depth, IsDir: info.IsDir(), Size: info.Size(), ModTime: info.ModTime(), Mode: entryMode(info)}, nil
 }
 
+// Resolves a symbolic link. Returns info of the target and whether it is a directory to read.
+// Returns error if the link must not be read
+type resolveFunc func(linkPath string, linkInfo fs.FileInfo) (fs.FileInfo, bool, error)
+
 type iterator struct {
 	parentPath string
 	depth      int
 	entries    []fs.DirEntry
+	join       func(elem ...string) string   // joins parent path and name of an entry
+	resolve    resolveFunc                   // resolves symbolic links. Links are not resolved when nil
+	more       func() ([]fs.DirEntry, error) // reads next batch of entries. Entries are all read when nil
+	closer     io.Closer                     // closes the source of batches. Nil when there is nothing to close
 	position   int
 	value      base.DirEntry
 	err        error
+	stopErr    error // error that stopped reading batches
 }
 
-func newIterator(parentPath string, depth int, entries []fs.DirEntry) base.Iterator[base.DirEntry] {
-	return &iterator{parentPath, depth, entries, -1, base.DirEntry{}, nil}
+func newIterator(parentPath string, depth int, entries []fs.DirEntry, join func(elem ...string) string) *iterator {
+	return &iterator{parentPath: parentPath, depth: depth, entries: entries, join: join, position: -1}
 }
 
+// Entry that fails to read its info is reported by Err and the rest are still read
 func (i *iterator) Next() bool {
-	if i.err != nil || i.entries == nil || i.position >= len(i.entries)-1 {
-		return false
+	i.err = nil
+	for i.position >= len(i.entries)-1 {
+		if i.more == nil {
+			i.err = i.stopErr
+			return false
+		}
+		entries, err := i.more()
+		i.entries, i.position = entries, -1
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				i.stopErr = err
+			}
+			i.Close()
+		}
 	}
 	i.position++
 	info, err := i.entries[i.position].Info()
 	if err != nil {
+		i.value = base.DirEntry{Path: i.join(i.parentPath, i.entries[i.position].Name()), Depth: i.depth}
 		i.err = err
-		return false
+		return true
+	}
+	path := i.join(i.parentPath, info.Name())
+	isDir := info.IsDir()
+	if i.resolve != nil && info.Mode()&fs.ModeSymlink != 0 {
+		info, isDir, err = i.resolve(path, info)
+		if err != nil {
+			i.value = base.DirEntry{Path: path, Depth: i.depth, Mode: entryMode(info)}
+			i.err = &fs.PathError{Op: "stat", Path: path, Err: err}
+			return true
+		}
 	}
 	i.value = base.DirEntry{
-		Path:    filepath.Join(i.parentPath, info.Name()),
+		Path:    path,
 		Depth:   i.depth,
-		IsDir:   info.IsDir(),
+		IsDir:   isDir,
 		Size:    info.Size(),
 		ModTime: info.ModTime(),
+		Mode:    entryMode(info),
 	}
 	return true
 }
@@ -72,3 +173,41 @@ func (i *iterator) Value() base.DirEntry {
 func (i *iterator) Err() error {
 	return i.err
 }
+
+// Stops reading batches and closes their source. Entries that are already read are still iterated
+func (i *iterator) Close() error {
+	i.more = nil
+	if i.closer == nil {
+		return nil
+	}
+	err := i.closer.Close()
+	i.closer = nil
+	return err
+}
+
+// Returns type bits of an entry. Directories are told by IsDir of an entry
+func entryMode(info fs.FileInfo) fs.FileMode {
+	return info.Mode().Type() &^ fs.ModeDir
+}
+
+// Resolves a symbolic link. Broken link keeps its own info and fails to open later.
+// Link to a directory that contains the link returns ErrLinkLoop, so walking it never loops
+func followLink(linkPath string, linkInfo fs.FileInfo) (fs.FileInfo, bool, error) {
+	info, err := os.Stat(linkPath)
+	if err != nil {
+		return linkInfo, false, nil
+	}
+	if !info.IsDir() {
+		return info, false, nil
+	}
+	dir, err := filepath.Abs(linkPath)
+	if err != nil {
+		return info, true, nil
+	}
+	for parent := filepath.Dir(dir); parent != dir; dir, parent = parent, filepath.Dir(parent) {
+		if parentInfo, err := os.Stat(parent); err == nil && os.SameFile(parentInfo, info) {
+			return linkInfo, false, ErrLinkLoop
+		}
+	}
+	return info, true, nil
+}
@@ -3,10 +3,13 @@ package reader
 import (
 	"bufio"
 	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"testing"
 	"time"
 
@@ -116,7 +119,7 @@ func testFileSystemReader_ReadRootEntry(t *testing.T, tempDir string) {
 }
 
 func TestIterator_Empty(t *testing.T) {
-	it := newIterator("a", 2, []fs.DirEntry{})
+	it := newIterator("a", 2, []fs.DirEntry{}, filepath.Join)
 
 	err := it.Err()
 	if err != nil {
@@ -159,7 +162,7 @@ func TestIterator_ErrorInTheMiddle(t *testing.T) {
 		&mockFsDirEntry{infoReturn: &mockFsFileInfo{sizeReturn: 256, modTimeReturn: t2, nameReturn: "c", isDirReturn: true}},
 		&mockFsDirEntry{infoError: errors.New("error"), nameReturn: "d", isDirReturn: true},
 		&mockFsDirEntry{infoReturn: &mockFsFileInfo{sizeReturn: 1024, modTimeReturn: t2, nameReturn: "e", isDirReturn: true}},
-	})
+	}, filepath.Join)
 
 	err := it.Err()
 	if err != nil {
@@ -194,7 +197,7 @@ func TestIterator_ErrorInTheMiddle(t *testing.T) {
 		t.Errorf("Third call to Value() returned %v", value)
 	}
 	next = it.Next()
-	if next {
+	if !next {
 		t.Errorf("Third call to Next() returned %v", next)
 	}
 	err = it.Err()
@@ -202,21 +205,33 @@ func TestIterator_ErrorInTheMiddle(t *testing.T) {
 		t.Errorf("Fourth call to Err() returned %v", err)
 	}
 	value = it.Value()
-	if !reflect.DeepEqual(value, base.DirEntry{Path: filepath.Join("a", "c"), Depth: 2, IsDir: true, Size: 256, ModTime: t2}) {
+	if !reflect.DeepEqual(value, base.DirEntry{Path: filepath.Join("a", "d"), Depth: 2}) {
 		t.Errorf("Fourth call to Value() returned %v", value)
 	}
 	next = it.Next()
-	if next {
+	if !next {
 		t.Errorf("Fourth call to Next() returned %v", next)
 	}
 	err = it.Err()
-	if err == nil || err.Error() != "error" {
+	if err != nil {
 		t.Errorf("Fifth call to Err() returned %v", err)
 	}
 	value = it.Value()
-	if !reflect.DeepEqual(value, base.DirEntry{Path: filepath.Join("a", "c"), Depth: 2, IsDir: true, Size: 256, ModTime: t2}) {
+	if !reflect.DeepEqual(value, base.DirEntry{Path: filepath.Join("a", "e"), Depth: 2, IsDir: true, Size: 1024, ModTime: t2}) {
 		t.Errorf("Fifth call to Value() returned %v", value)
 	}
+	next = it.Next()
+	if next {
+		t.Errorf("Fifth call to Next() returned %v", next)
+	}
+	err = it.Err()
+	if err != nil {
+		t.Errorf("Sixth call to Err() returned %v", err)
+	}
+	value = it.Value()
+	if !reflect.DeepEqual(value, base.DirEntry{Path: filepath.Join("a", "e"), Depth: 2, IsDir: true, Size: 1024, ModTime: t2}) {
+		t.Errorf("Sixth call to Value() returned %v", value)
+	}
 }
 
 func TestIterator_NoErrorsTilTheEnd(t *testing.T) {
@@ -225,7 +240,7 @@ func TestIterator_NoErrorsTilTheEnd(t *testing.T) {
 	it := newIterator("a", 2, []fs.DirEntry{
 		&mockFsDirEntry{infoReturn: &mockFsFileInfo{sizeReturn: 100, modTimeReturn: t1, nameReturn: "b", isDirReturn: false}},
 		&mockFsDirEntry{infoReturn: &mockFsFileInfo{sizeReturn: 256, modTimeReturn: t2, nameReturn: "c", isDirReturn: true}},
-	})
+	}, filepath.Join)
 
 	err := it.Err()
 	if err != nil {
@@ -341,3 +356,194 @@ func (m *mockFsFileInfo) Mode() fs.FileMode {
 func (m *mockFsFileInfo) Sys() any {
 	return m.sysReturn
 }
+
+func TestFileSystemReader_Follow(t *testing.T) {
+	tempDir := t.TempDir()
+	dirPath := filepath.Join(tempDir, "dir")
+	if err := os.Mkdir(dirPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dirPath, "file.txt"), []byte("this is for test"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(tempDir, "other"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		"broken": filepath.Join(tempDir, "missing"),
+		"file":   filepath.Join(dirPath, "file.txt"),
+		"loop":   tempDir,
+		"other":  filepath.Join(tempDir, "other"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(dirPath, name)); err != nil {
+			t.Skip("Symlinks are not supported", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		options  []FileSystemOption
+		wantDir  map[string]bool
+		wantLink map[string]bool
+		wantErr  map[string]error
+	}{
+		{
+			"No follow",
+			nil,
+			map[string]bool{"broken": false, "file": false, "file.txt": false, "loop": false, "other": false},
+			map[string]bool{"broken": true, "file": true, "file.txt": false, "loop": true, "other": true},
+			map[string]error{"broken": ErrBrokenLink},
+		},
+		{
+			"Follow",
+			[]FileSystemOption{WithFollow()},
+			map[string]bool{"broken": false, "file": false, "file.txt": false, "loop": false, "other": true},
+			map[string]bool{"broken": true, "file": false, "file.txt": false, "loop": true, "other": false},
+			map[string]error{"broken": ErrBrokenLink, "loop": ErrLinkLoop},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsr := NewFileSystem(tt.options...)
+			iter, err := fsr.ReadDir(base.DirEntry{Path: dirPath, Depth: 1, IsDir: true})
+			if err != nil {
+				t.Fatalf("ReadDir error: %v", err)
+			}
+			gotDir := make(map[string]bool)
+			gotLink := make(map[string]bool)
+			for iter.Next() {
+				entry := iter.Value()
+				name := filepath.Base(entry.Path)
+				gotDir[name] = entry.IsDir
+				gotLink[name] = entry.Mode&fs.ModeSymlink != 0
+				// Loop is reported by the iterator and is not opened
+				if err := iter.Err(); err != nil {
+					if !errors.Is(err, tt.wantErr[name]) {
+						t.Errorf("Entry %s error = %v, want %v", name, err, tt.wantErr[name])
+					}
+					continue
+				}
+				if entry.IsDir {
+					continue
+				}
+				file, err := fsr.OpenFile(entry)
+				if !errors.Is(err, tt.wantErr[name]) {
+					t.Errorf("OpenFile of %s error = %v, want %v", name, err, tt.wantErr[name])
+				}
+				if err == nil {
+					file.Close()
+				}
+			}
+			if iter.Err() != nil {
+				t.Errorf("Iterator error: %v", iter.Err())
+			}
+			if !reflect.DeepEqual(gotDir, tt.wantDir) {
+				t.Errorf("ReadDir returned directories %v, want %v", gotDir, tt.wantDir)
+			}
+			if !reflect.DeepEqual(gotLink, tt.wantLink) {
+				t.Errorf("ReadDir returned links %v, want %v", gotLink, tt.wantLink)
+			}
+
+			// Root is resolved either way
+			root, err := fsr.ReadRootEntry(filepath.Join(dirPath, "file"), 0)
+			if err != nil || root.IsDir || root.Mode != 0 {
+				t.Errorf("ReadRootEntry of link to file returned %v, %v", root, err)
+			}
+			root, err = fsr.ReadRootEntry(filepath.Join(dirPath, "other"), 0)
+			if err != nil || !root.IsDir || root.Mode != 0 {
+				t.Errorf("ReadRootEntry of link to dir returned %v, %v", root, err)
+			}
+		})
+	}
+}
+
+func TestFileSystemReader_Unsorted(t *testing.T) {
+	tempDir := t.TempDir()
+	var want []string
+	for i := 0; i < 7; i++ {
+		name := fmt.Sprintf("file%d.txt", i)
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("this is for test"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, filepath.Join(tempDir, name))
+	}
+	if err := os.Mkdir(filepath.Join(tempDir, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, filepath.Join(tempDir, "dir"))
+	slices.Sort(want)
+
+	fsr := &FileSystem{unsorted: true, batchSize: 3}
+	iter, err := fsr.ReadDir(base.DirEntry{Path: tempDir, IsDir: true})
+	if err != nil {
+		t.Fatalf("ReadDir error: %v", err)
+	}
+	var got []string
+	for iter.Next() {
+		entry := iter.Value()
+		if iter.Err() != nil || entry.Depth != 1 || entry.IsDir != (filepath.Base(entry.Path) == "dir") {
+			t.Errorf("Next returned %v, %v", entry, iter.Err())
+		}
+		got = append(got, entry.Path)
+	}
+	if iter.Err() != nil {
+		t.Errorf("Iterator error: %v", iter.Err())
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("ReadDir returned %v, want %v", got, want)
+	}
+
+	// Closed iterator yields the rest of the current batch only
+	iter, err = fsr.ReadDir(base.DirEntry{Path: tempDir, IsDir: true})
+	if err != nil {
+		t.Fatalf("ReadDir error: %v", err)
+	}
+	if !iter.Next() {
+		t.Fatalf("Next returned false")
+	}
+	if err := iter.(io.Closer).Close(); err != nil {
+		t.Errorf("Close error: %v", err)
+	}
+	count := 1
+	for iter.Next() {
+		count++
+	}
+	if count != 3 {
+		t.Errorf("Closed iterator returned %v entries, want 3", count)
+	}
+
+	if _, err := fsr.ReadDir(base.DirEntry{Path: filepath.Join(tempDir, "missing"), IsDir: true}); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadDir of missing dir error = %v", err)
+	}
+}
+
+func TestIterator_BatchError(t *testing.T) {
+	batches := [][]fs.DirEntry{
+		{&mockFsDirEntry{infoReturn: &mockFsFileInfo{nameReturn: "b"}}},
+		{&mockFsDirEntry{infoReturn: &mockFsFileInfo{nameReturn: "c"}}},
+	}
+	it := newIterator("a", 1, nil, filepath.Join)
+	it.more = func() ([]fs.DirEntry, error) {
+		batch := batches[0]
+		batches = batches[1:]
+		if len(batches) == 0 {
+			return batch, errors.New("error")
+		}
+		return batch, nil
+	}
+	var got []string
+	for it.Next() {
+		got = append(got, it.Value().Path)
+	}
+	if !slices.Equal(got, []string{filepath.Join("a", "b"), filepath.Join("a", "c")}) {
+		t.Errorf("Next returned %v", got)
+	}
+	if it.Err() == nil || it.Err().Error() != "error" {
+		t.Errorf("Err returned %v", it.Err())
+	}
+	if it.Next() || it.Err() == nil {
+		t.Errorf("Next after the end returned true or no error")
+	}
+}
@@ -0,0 +1,38 @@
+package reader
+
+import (
+	"io"
+	"io/fs"
+	"path"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+// Reader of any file system like embed.FS or fstest.MapFS.
+// Paths are slash-separated and unrooted as io/fs requires. Root of a file system is "."
+type FS struct {
+	fsys fs.FS
+}
+
+func NewFS(fsys fs.FS) base.Reader {
+	return &FS{fsys}
+}
+
+func (f *FS) OpenFile(fileEntry base.DirEntry) (io.ReadCloser, error) {
+	return f.fsys.Open(fileEntry.Path)
+}
+
+// Uses fs.ReadDirFS when file system implements it
+func (f *FS) ReadDir(dirEntry base.DirEntry) (base.Iterator[base.DirEntry], error) {
+	fsDirEntries, err := fs.ReadDir(f.fsys, dirEntry.Path)
+	return newIterator(dirEntry.Path, dirEntry.Depth+1, fsDirEntries, path.Join), err
+}
+
+// Uses fs.StatFS when file system implements it
+func (f *FS) ReadRootEntry(name string, depth int) (base.DirEntry, error) {
+	info, err := fs.Stat(f.fsys, name)
+	if err != nil {
+		return base.DirEntry{}, err
+	}
+	return base.DirEntry{Path: name, Depth: depth, IsDir: info.IsDir(), Size: info.Size(), ModTime: info.ModTime(), Mode: entryMode(info)}, nil
+}
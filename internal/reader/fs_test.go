@@ -0,0 +1,102 @@
+package reader
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+func TestFS(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsr := NewFS(fstest.MapFS{
+		"thisisfortest/thisisfortest.txt": {Data: []byte("this is\nfor test"), ModTime: modTime},
+		"thisisfortest/empty":             {Mode: fs.ModeDir, ModTime: modTime},
+		"thisisfortest/pipe":              {Mode: fs.ModeNamedPipe, ModTime: modTime},
+	})
+
+	file, err := fsr.OpenFile(base.DirEntry{Path: "thisisfortest/thisisfortest.txt"})
+	if err != nil {
+		t.Fatalf("OpenFile error: %v", err)
+	}
+	content, err := io.ReadAll(file)
+	file.Close()
+	if err != nil || string(content) != "this is\nfor test" {
+		t.Errorf("ReadAll returned %q, %v", content, err)
+	}
+	_, err = fsr.OpenFile(base.DirEntry{Path: "missing"})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("OpenFile of missing file returned %v", err)
+	}
+
+	root, err := fsr.ReadRootEntry(".", 1)
+	if err != nil || !reflect.DeepEqual(root, base.DirEntry{Path: ".", Depth: 1, IsDir: true}) {
+		t.Errorf("ReadRootEntry returned %v, %v", root, err)
+	}
+
+	iter, err := fsr.ReadDir(base.DirEntry{Path: "thisisfortest", Depth: 1, IsDir: true})
+	if err != nil {
+		t.Fatalf("ReadDir error: %v", err)
+	}
+	var entries []base.DirEntry
+	for iter.Next() {
+		entries = append(entries, iter.Value())
+	}
+	want := []base.DirEntry{
+		{Path: "thisisfortest/empty", Depth: 2, IsDir: true, ModTime: modTime},
+		{Path: "thisisfortest/pipe", Depth: 2, ModTime: modTime, Mode: fs.ModeNamedPipe},
+		{Path: "thisisfortest/thisisfortest.txt", Depth: 2, Size: 16, ModTime: modTime},
+	}
+	if iter.Err() != nil || !reflect.DeepEqual(entries, want) {
+		t.Errorf("ReadDir returned %v, %v", entries, iter.Err())
+	}
+}
+
+// FS over mock entries must read the same entries as mock reader does
+func TestFS_SameAsMockReader(t *testing.T) {
+	for _, seed := range []int64{103, 10, 18} {
+		entries, rootName, _ := NewEntriesGen(seed, 20, 4, 5, 7, time.Now().UTC(), 48).Generate()
+		want := walkForTest(t, NewMockReader(entries), rootName)
+		got := walkForTest(t, NewMockFS(entries), rootName)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Seed %v: FS read %v, want %v", seed, got, want)
+		}
+	}
+}
+
+// Reads all entries and contents of files. Generated entries are not sorted so order is not kept
+func walkForTest(t *testing.T, r base.Reader, rootName string) map[base.DirEntry]string {
+	root, err := r.ReadRootEntry(rootName, 0)
+	if err != nil {
+		t.Fatalf("ReadRootEntry error: %v", err)
+	}
+	walked := make(map[base.DirEntry]string)
+	var walk func(entry base.DirEntry)
+	walk = func(entry base.DirEntry) {
+		walked[entry] = ""
+		if !entry.IsDir {
+			file, err := r.OpenFile(entry)
+			if err != nil {
+				t.Fatalf("OpenFile error: %v", err)
+			}
+			content, _ := io.ReadAll(file)
+			file.Close()
+			walked[entry] = string(content)
+			return
+		}
+		iter, err := r.ReadDir(entry)
+		if err != nil {
+			t.Fatalf("ReadDir error: %v", err)
+		}
+		for iter.Next() {
+			walk(iter.Value())
+		}
+	}
+	walk(root)
+	return walked
+}
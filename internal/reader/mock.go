@@ -3,8 +3,11 @@ package reader
 import (
 	"errors"
 	"io"
+	"io/fs"
+	"path"
 	"slices"
 	"strings"
+	"testing/fstest"
 	"time"
 
 	"github.com/pi-kei/mgrep/internal/base"
@@ -21,6 +24,8 @@ type MockEntry struct{
 	ModTime time.Time   // modification time
 	Content *string     // files must have this not equal to nil, dirs must have this equal to nil
 	Err error           // error reading this entry or nil
+	StatErr error       // error reading info of this entry or nil. Entry is skipped by iterator of its parent then
+	Mode fs.FileMode    // type bits of a special file like fs.ModeNamedPipe. Zero for regular files and dirs
 	children []string   // if present then it means it has precalculated children. for dirs only
 }
 
@@ -78,11 +83,14 @@ func (r *mockReader) ReadRootEntry(name string, depth int) (base.DirEntry, error
 	if entry.Err != nil {
 		return base.DirEntry{}, entry.Err
 	}
+	if entry.StatErr != nil {
+		return base.DirEntry{}, entry.StatErr
+	}
 	var size int64
 	if entry.Content != nil {
 		size = int64(len(*entry.Content))
 	}
-	return base.DirEntry{Path: name, Depth: depth, IsDir: entry.Content == nil, Size: size, ModTime: entry.ModTime}, nil
+	return base.DirEntry{Path: name, Depth: depth, IsDir: entry.Content == nil, Size: size, ModTime: entry.ModTime, Mode: entry.Mode}, nil
 }
 
 type mockIterator struct {
@@ -99,21 +107,23 @@ func newMockIterator(entries MockEntries, children []string, depth int) base.Ite
 }
 
 func (i *mockIterator) Next() bool {
-	if i.err != nil || i.children == nil || i.position >= len(i.children) - 1 {
+	i.err = nil
+	if i.children == nil || i.position >= len(i.children) - 1 {
 		return false
 	}
 	i.position++
 	path := i.children[i.position]
 	entry := i.entries[path]
-	i.err = entry.Err
-	if i.err != nil {
-		return false
+	if entry.StatErr != nil {
+		i.value = base.DirEntry{Path: path, Depth: i.depth}
+		i.err = entry.StatErr
+		return true
 	}
 	var size int64
 	if entry.Content != nil {
 		size = int64(len(*entry.Content))
 	}
-	i.value = base.DirEntry{Path: path, Depth: i.depth, IsDir: entry.Content == nil, Size: size, ModTime: entry.ModTime}
+	i.value = base.DirEntry{Path: path, Depth: i.depth, IsDir: entry.Content == nil, Size: size, ModTime: entry.ModTime, Mode: entry.Mode}
 	return true
 }
 
@@ -123,4 +133,67 @@ func (i *mockIterator) Value() base.DirEntry {
 
 func (i *mockIterator) Err() error {
 	return i.err
-}
\ No newline at end of file
+}
+
+// File system of mock entries. Entries that have errors fail to open or to read their info
+type mockFS struct {
+	fstest.MapFS
+	errs map[string]error
+	statErrs map[string]error
+}
+
+// Returns FS reader over the same entries as NewMockReader has
+func NewMockFS(entries MockEntries) base.Reader {
+	mapFS := mockFS{fstest.MapFS{}, map[string]error{}, map[string]error{}}
+	for path, entry := range entries {
+		if entry.Content == nil {
+			mapFS.MapFS[path] = &fstest.MapFile{Mode: fs.ModeDir | 0755, ModTime: entry.ModTime}
+		} else {
+			mapFS.MapFS[path] = &fstest.MapFile{Data: []byte(*entry.Content), Mode: entry.Mode | 0644, ModTime: entry.ModTime}
+		}
+		if entry.Err != nil {
+			mapFS.errs[path] = entry.Err
+		}
+		if entry.StatErr != nil {
+			mapFS.statErrs[path] = entry.StatErr
+		}
+	}
+	return NewFS(mapFS)
+}
+
+func (m mockFS) Open(name string) (fs.File, error) {
+	if err, ok := m.errs[name]; ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return m.MapFS.Open(name)
+}
+
+func (m mockFS) Stat(name string) (fs.FileInfo, error) {
+	if err, ok := m.statErrs[name]; ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return m.MapFS.Stat(name)
+}
+
+func (m mockFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err, ok := m.errs[name]; ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	entries, err := m.MapFS.ReadDir(name)
+	for i, entry := range entries {
+		if statErr, ok := m.statErrs[path.Join(name, entry.Name())]; ok {
+			entries[i] = mockFSDirEntry{entry, &fs.PathError{Op: "lstat", Path: path.Join(name, entry.Name()), Err: statErr}}
+		}
+	}
+	return entries, err
+}
+
+// Dir entry that fails to read its info
+type mockFSDirEntry struct {
+	fs.DirEntry
+	err error
+}
+
+func (e mockFSDirEntry) Info() (fs.FileInfo, error) {
+	return nil, e.err
+}
@@ -2,45 +2,362 @@ package scanner
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
-	"regexp"
+	"io"
+	"sort"
+	"unicode/utf8"
 
 	"github.com/pi-kei/mgrep/internal/base"
 )
 
+// How to handle binary files
+type BinaryMode int
+
+const (
+	BinaryMatches BinaryMode = iota // report only the first match without a line
+	BinarySkip                      // do not scan binary files
+	BinaryText                      // scan binary files as text
+)
+
+// Number of bytes at the start of a file that are checked to detect a binary file
+const binarySniffSize = 8 * 1024
+
+// Size of a buffer of a file. Longer lines are scanned in parts
+const lineBufferSize = 64 * 1024
+
 type Line struct {
-	reader base.Reader
+	reader     base.Reader
+	before     int        // number of context lines before a match
+	after      int        // number of context lines after a match
+	binaryMode BinaryMode // how to handle binary files
+	invert     bool       // whether lines that do not match are reported
+	multiLine  bool       // whether matches can span several lines
+	maxSize    int64      // max number of bytes read from a file in multi-line mode
+	maxLength  int        // max number of runes of a reported line. Zero means no limit
+}
+
+var ErrFileTooLarge = errors.New("file is too large")
+
+type LineOption func(*Line)
+
+// Sets how to handle binary files. Default is BinaryMatches
+func WithBinaryMode(mode BinaryMode) LineOption {
+	return func(l *Line) {
+		l.binaryMode = mode
+	}
+}
+
+// Makes scanner report lines that do not match instead of lines that do.
+// Reported lines have no matches
+func WithInvert() LineOption {
+	return func(l *Line) {
+		l.invert = true
+	}
 }
 
-func NewLine(reader base.Reader) base.Scanner {
-	return &Line{reader}
+// Makes scanner run matcher over the whole file so matches can span several lines.
+// File must not be larger than maxSize bytes. Lines are still scanned one by one
+// when matcher can not match a new line
+func WithMultiLine(maxSize int64) LineOption {
+	return func(l *Line) {
+		l.multiLine = true
+		l.maxSize = max(maxSize, 0)
+	}
 }
 
-func (l *Line) ScanFile(fileEntry base.DirEntry, searchRegexp *regexp.Regexp, callback func(base.SearchResult) error) error {
+// Cuts reported lines that are longer than maxLength runes around the first match.
+// Zero means no limit. Lines that are longer than the buffer are cut anyway
+func WithMaxLength(maxLength int) LineOption {
+	return func(l *Line) {
+		l.maxLength = max(maxLength, 0)
+	}
+}
+
+// Sets number of context lines to report before and after each match.
+// Overlapping context windows are merged so each line is reported once
+func WithContext(before, after int) LineOption {
+	return func(l *Line) {
+		l.before = max(before, 0)
+		l.after = max(after, 0)
+	}
+}
+
+func NewLine(reader base.Reader, options ...LineOption) base.Scanner {
+	scanner := Line{reader: reader}
+	for _, option := range options {
+		option(&scanner)
+	}
+	return &scanner
+}
+
+func (l *Line) ScanFile(fileEntry base.DirEntry, matcher base.Matcher, callback func(base.SearchResult) error) error {
 	file, err := l.reader.OpenFile(fileEntry)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
-	scanner := bufio.NewScanner(file)
-	for lineNumber := 1; scanner.Scan(); lineNumber++ {
-		if slice := searchRegexp.FindIndex(scanner.Bytes()); slice != nil {
-			err := callback(base.SearchResult{Path: fileEntry.Path, LineNumber: lineNumber, StartIndex: slice[0], EndIndex: slice[1], Line: scanner.Text()})
-			if err != nil {
-				if errors.Is(err, base.ErrSkipItem) {
-					continue
-				}
-				if errors.Is(err, base.ErrSkipAll) {
-					return nil
+	// Returns true when scanning must be stopped
+	emit := func(result base.SearchResult) (bool, error) {
+		err := callback(result)
+		if err != nil {
+			if errors.Is(err, base.ErrSkipItem) || errors.Is(err, base.ErrSkipAll) {
+				return true, nil
+			}
+			return true, err
+		}
+		return false, nil
+	}
+	literals := make([][]byte, len(matcher.Literals()))
+	for i, literal := range matcher.Literals() {
+		literals[i] = []byte(literal)
+	}
+	bufferedFile := bufio.NewReaderSize(file, lineBufferSize)
+	binary := false
+	if l.binaryMode != BinaryText {
+		block, _ := bufferedFile.Peek(binarySniffSize)
+		binary = isBinary(block, len(block) == binarySniffSize)
+		if binary && l.binaryMode == BinarySkip {
+			return base.ErrSkipItem
+		}
+	}
+	if l.multiLine && matcher.MultiLine() {
+		return l.scanBuffer(fileEntry.Path, bufferedFile, matcher, literals, binary, emit)
+	}
+	// Inverted search only needs to know whether a line matches
+	limit := -1
+	if l.invert || binary {
+		limit = 1
+	}
+	find := func(text []byte) []base.Match {
+		return findAll(matcher, literals, text, limit)
+	}
+	lines := lineReader{reader: bufferedFile}
+	beforeLines := newLineRing(l.before)
+	afterLeft := 0
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := lines.next(find)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		matched := line.matched
+		matches := line.matches
+		if l.invert {
+			matched = !matched
+			matches = nil
+		}
+		if binary {
+			if matched {
+				_, err := emit(base.SearchResult{Path: fileEntry.Path, LineNumber: lineNumber, IsBinary: true})
+				return err
+			}
+			continue
+		}
+		if matched {
+			for _, result := range beforeLines.drain() {
+				if stop, err := emit(result); stop {
+					return err
 				}
+			}
+			afterLeft = l.after
+			if stop, err := emit(l.newResult(fileEntry.Path, lineNumber, line, matches, false)); stop {
+				return err
+			}
+		} else if afterLeft > 0 {
+			afterLeft--
+			if stop, err := emit(l.newResult(fileEntry.Path, lineNumber, line, nil, true)); stop {
 				return err
 			}
+		} else if l.before > 0 {
+			beforeLines.push(l.newResult(fileEntry.Path, lineNumber, line, nil, true))
+		}
+	}
+}
+
+// Makes a result of a read line. Cuts the line when it is longer than max length
+func (l *Line) newResult(path string, lineNumber int, line readLine, matches []base.Match, isContext bool) base.SearchResult {
+	result := base.SearchResult{
+		Path:         path,
+		LineNumber:   lineNumber,
+		Line:         string(line.text),
+		Matches:      matches,
+		IsContext:    isContext,
+		Truncated:    line.truncated,
+		LineOffset:   line.offset,
+		ColumnOffset: line.column,
+	}
+	if l.maxLength > 0 {
+		truncateResult(&result, l.maxLength)
+	}
+	return result
+}
+
+// Bounds of a line in a buffer
+type lineBounds struct {
+	start int // offset of the first byte
+	end   int // offset after the last byte excluding line ending
+}
+
+// Lines of a buffer that have matches or context lines
+type lineGroup struct {
+	first   int          // index of the first line
+	last    int          // index of the last line
+	matches []base.Match // matches with offsets in the buffer
+}
+
+// Scans a whole file as a single buffer
+func (l *Line) scanBuffer(path string, file io.Reader, matcher base.Matcher, literals [][]byte, binary bool, emit func(base.SearchResult) (bool, error)) error {
+	content, err := io.ReadAll(io.LimitReader(file, l.maxSize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(content)) > l.maxSize {
+		return ErrFileTooLarge
+	}
+	lines := splitBuffer(content)
+	if len(lines) == 0 {
+		return nil
+	}
+	// Returns index of a line that has a byte at the offset
+	lineIndex := func(offset int) int {
+		return sort.Search(len(lines), func(i int) bool { return lines[i].start > offset }) - 1
+	}
+
+	var groups []lineGroup
+	for _, match := range findAll(matcher, literals, content, -1) {
+		first := lineIndex(match.StartIndex)
+		last := first
+		if match.EndIndex > match.StartIndex {
+			last = lineIndex(match.EndIndex - 1)
+		}
+		if len(groups) > 0 && first <= groups[len(groups)-1].last {
+			group := &groups[len(groups)-1]
+			group.last = max(group.last, last)
+			group.matches = append(group.matches, match)
+			continue
+		}
+		groups = append(groups, lineGroup{first, last, []base.Match{match}})
+	}
+	if l.invert {
+		var inverted []lineGroup
+		next := 0
+		for _, group := range append(groups, lineGroup{first: len(lines)}) {
+			for ; next < group.first; next++ {
+				inverted = append(inverted, lineGroup{first: next, last: next})
+			}
+			next = group.last + 1
+		}
+		groups = inverted
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+	if binary {
+		_, err := emit(base.SearchResult{Path: path, LineNumber: groups[0].first + 1, IsBinary: true})
+		return err
+	}
+
+	emitLines := func(group lineGroup, isContext bool) (bool, error) {
+		start := lines[group.first].start
+		end := lines[group.last].end
+		result := base.SearchResult{Path: path, LineNumber: group.first + 1, Line: string(content[start:end]), IsContext: isContext}
+		if group.last > group.first {
+			result.EndLineNumber = group.last + 1
+		}
+
+		for _, match := range group.matches {
+			// Line endings are not part of the line so matches of them are cut
+			result.Matches = append(result.Matches, base.Match{
+				StartIndex: min(match.StartIndex, end) - start,
+				EndIndex:   min(match.EndIndex, end) - start,
+				Pattern:    match.Pattern,
+			})
+		}
+		if l.maxLength > 0 && result.EndLineNumber == 0 {
+			truncateResult(&result, l.maxLength)
+		}
+		return emit(result)
+	}
+	// Index of the next line that is not emitted yet
+	next := 0
+	// Index of the last line of after context of a previous group
+	afterLast := -1
+	for _, group := range groups {
+		for i := next; i < group.first; i++ {
+			if i <= afterLast || i >= group.first-l.before {
+				if stop, err := emitLines(lineGroup{first: i, last: i}, true); stop {
+					return err
+				}
+			}
+		}
+		if stop, err := emitLines(group, false); stop {
+			return err
+		}
+		next = group.last + 1
+		afterLast = group.last + l.after
+	}
+	for i := next; i <= afterLast && i < len(lines); i++ {
+		if stop, err := emitLines(lineGroup{first: i, last: i}, true); stop {
+			return err
 		}
 	}
 	return nil
 }
 
-func (l *Line) ScanDirs(rootPath string, depth int, callback func(base.DirEntry) error) error {
+// Splits a buffer into lines the same way bufio.ScanLines does
+func splitBuffer(content []byte) []lineBounds {
+	var lines []lineBounds
+	for start := 0; start < len(content); {
+		end := len(content)
+		next := end
+		if i := bytes.IndexByte(content[start:], '\n'); i >= 0 {
+			end = start + i
+			next = end + 1
+		}
+		if end > start && content[end-1] == '\r' {
+			end--
+		}
+		lines = append(lines, lineBounds{start, end})
+		start = next
+	}
+	return lines
+}
+
+// Runs matcher on a text that has all required literals
+func findAll(matcher base.Matcher, literals [][]byte, text []byte, n int) []base.Match {
+	for _, literal := range literals {
+		if !bytes.Contains(text, literal) {
+			return nil
+		}
+	}
+	return matcher.FindAll(text, n)
+}
+
+// Checks if a block from the start of a file looks like binary data.
+// It does when it has NUL bytes or too many bytes that are not valid UTF-8.
+// Block that is cut may end with an incomplete rune that is not counted as invalid
+func isBinary(block []byte, cut bool) bool {
+	if bytes.IndexByte(block, 0) >= 0 {
+		return true
+	}
+	invalid := 0
+	for i := 0; i < len(block); {
+		r, size := utf8.DecodeRune(block[i:])
+		if r == utf8.RuneError && size == 1 {
+			if cut && !utf8.FullRune(block[i:]) {
+				break
+			}
+			invalid++
+		}
+		i += size
+	}
+	return invalid*10 > len(block)
+}
+
+func (l *Line) ScanDirs(rootPath string, depth int, callback func(base.DirEntry) error, warn func(*base.Warning)) error {
 	rootDirEntry, rootErr := l.reader.ReadRootEntry(rootPath, depth)
 	if rootErr != nil {
 		return rootErr
@@ -62,12 +379,29 @@ func (l *Line) ScanDirs(rootPath string, depth int, callback func(base.DirEntry)
 
 	var scanDir func(base.DirEntry, func(base.DirEntry) error) error
 	scanDir = func(dirEntry base.DirEntry, callback func(base.DirEntry) error) error {
+		// Directory below the root that cannot be read is skipped with a warning
+		dirErr := func(err error) error {
+			if err == nil || dirEntry.Path == rootDirEntry.Path || warn == nil {
+				return err
+			}
+			warn(&base.Warning{Path: dirEntry.Path, Err: err})
+			return nil
+		}
 		iter, err := l.reader.ReadDir(dirEntry)
 		if iter == nil {
-			return err
+			return dirErr(err)
+		}
+		if closer, ok := iter.(io.Closer); ok {
+			defer closer.Close()
 		}
 		for iter.Next() {
 			entry := iter.Value()
+			if entryErr := iter.Err(); entryErr != nil {
+				if warn != nil {
+					warn(&base.Warning{Path: entry.Path, Err: entryErr})
+				}
+				continue
+			}
 			var loopErr error
 			if entry.IsDir {
 				loopErr = callback(entry)
@@ -92,9 +426,9 @@ func (l *Line) ScanDirs(rootPath string, depth int, callback func(base.DirEntry)
 			}
 		}
 		if iter.Err() != nil {
-			return iter.Err()
+			return dirErr(iter.Err())
 		}
-		return err
+		return dirErr(err)
 	}
 
 	rootErr = callback(rootDirEntry)
@@ -113,3 +447,39 @@ func (l *Line) ScanDirs(rootPath string, depth int, callback func(base.DirEntry)
 	}
 	return rootErr
 }
+
+// Fixed size buffer that keeps last pushed lines
+type lineRing struct {
+	lines []base.SearchResult
+	start int
+	count int
+}
+
+func newLineRing(size int) *lineRing {
+	return &lineRing{make([]base.SearchResult, size), 0, 0}
+}
+
+// Adds a line. Drops the oldest one if buffer is full
+func (r *lineRing) push(line base.SearchResult) {
+	if len(r.lines) == 0 {
+		return
+	}
+	if r.count < len(r.lines) {
+		r.lines[(r.start+r.count)%len(r.lines)] = line
+		r.count++
+		return
+	}
+	r.lines[r.start] = line
+	r.start = (r.start + 1) % len(r.lines)
+}
+
+// Returns buffered lines from oldest to newest and empties the buffer
+func (r *lineRing) drain() []base.SearchResult {
+	lines := make([]base.SearchResult, r.count)
+	for i := range lines {
+		lines[i] = r.lines[(r.start+i)%len(r.lines)]
+	}
+	r.start = 0
+	r.count = 0
+	return lines
+}
@@ -4,10 +4,12 @@ import (
 	"errors"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/pi-kei/mgrep/internal/base"
+	"github.com/pi-kei/mgrep/internal/matcher"
 	"github.com/pi-kei/mgrep/internal/reader"
 )
 
@@ -45,7 +47,7 @@ func TestLineScanner_ScanDirs(t *testing.T) {
 		}
 		calledTimes++
 		return nil
-	})
+	}, nil)
 	if calledTimes != len(callbacks) {
 		t.Errorf("Callback called %v times", calledTimes)
 	}
@@ -62,7 +64,7 @@ func TestLineScanner_ScanDirs(t *testing.T) {
 		}
 		calledTimes++
 		return nil
-	})
+	}, nil)
 	if calledTimes != len(callbacks) {
 		t.Errorf("Callback called %v times", calledTimes)
 	}
@@ -81,7 +83,7 @@ func TestLineScanner_ScanDirs(t *testing.T) {
 		}
 		calledTimes++
 		return nil
-	})
+	}, nil)
 	if calledTimes != len(callbacks) {
 		t.Errorf("Callback called %v times", calledTimes)
 	}
@@ -100,7 +102,7 @@ func TestLineScanner_ScanDirs(t *testing.T) {
 		}
 		calledTimes++
 		return base.ErrSkipItem
-	})
+	}, nil)
 	if calledTimes != len(callbacks) {
 		t.Errorf("Callback called %v times", calledTimes)
 	}
@@ -119,7 +121,7 @@ func TestLineScanner_ScanDirs(t *testing.T) {
 		}
 		calledTimes++
 		return base.ErrSkipAll
-	})
+	}, nil)
 	if calledTimes != len(callbacks) {
 		t.Errorf("Callback called %v times", calledTimes)
 	}
@@ -139,7 +141,7 @@ func TestLineScanner_ScanDirs(t *testing.T) {
 		}
 		calledTimes++
 		return testError
-	})
+	}, nil)
 	if calledTimes != len(callbacks) {
 		t.Errorf("Callback called %v times", calledTimes)
 	}
@@ -158,7 +160,7 @@ func TestLineScanner_ScanDirs(t *testing.T) {
 		}
 		calledTimes++
 		return base.ErrSkipItem
-	})
+	}, nil)
 	if calledTimes != len(callbacks) {
 		t.Errorf("Callback called %v times", calledTimes)
 	}
@@ -177,7 +179,7 @@ func TestLineScanner_ScanDirs(t *testing.T) {
 		}
 		calledTimes++
 		return base.ErrSkipAll
-	})
+	}, nil)
 	if calledTimes != len(callbacks) {
 		t.Errorf("Callback called %v times", calledTimes)
 	}
@@ -197,7 +199,7 @@ func TestLineScanner_ScanDirs(t *testing.T) {
 		}
 		calledTimes++
 		return testError
-	})
+	}, nil)
 	if calledTimes != len(callbacks) {
 		t.Errorf("Callback called %v times", calledTimes)
 	}
@@ -206,6 +208,49 @@ func TestLineScanner_ScanDirs(t *testing.T) {
 	}
 }
 
+func TestLineScanner_ScanDirs_Unreadable(t *testing.T) {
+	now := time.Now().UTC()
+	content := "hello"
+	readErr := errors.New("permission denied")
+	testEntries := reader.MockEntries{
+		"aaa":         {ModTime: now},
+		"aaa/bbb":     {ModTime: now, Err: readErr},
+		"aaa/ccc":     {ModTime: now},
+		"aaa/ccc/ddd": {ModTime: now, Content: &content},
+		"aaa/eee":     {ModTime: now, Content: &content},
+	}
+	scanner := NewLine(reader.NewMockReader(testEntries))
+
+	// Unreadable subdirectory is passed to warn, siblings are still scanned
+	var paths []string
+	var warnings []*base.Warning
+	err := scanner.ScanDirs("aaa", 0, func(entry base.DirEntry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	}, func(warning *base.Warning) {
+		warnings = append(warnings, warning)
+	})
+	if err != nil {
+		t.Errorf("ScanDirs returned error %v", err)
+	}
+	if expected := []string{"aaa", "aaa/bbb", "aaa/ccc", "aaa/ccc/ddd", "aaa/eee"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Callback called with %v expected %v", paths, expected)
+	}
+	if len(warnings) != 1 || warnings[0].Path != "aaa/bbb" || !errors.Is(warnings[0], readErr) {
+		t.Errorf("Invalid warnings %v", warnings)
+	}
+
+	// Unreadable root is an error
+	err = scanner.ScanDirs("aaa/bbb", 1, func(entry base.DirEntry) error {
+		return nil
+	}, func(warning *base.Warning) {
+		t.Errorf("Unexpected warning %v", warning)
+	})
+	if !errors.Is(err, readErr) {
+		t.Errorf("ScanDirs returned error %v", err)
+	}
+}
+
 func TestLineScanner_ScanFile(t *testing.T) {
 	now := time.Now().UTC()
 	content := "hello\nsecond line hhhhh\nthird line"
@@ -225,11 +270,11 @@ func TestLineScanner_ScanFile(t *testing.T) {
 	// Scan lines
 	fileEntry := base.DirEntry{Path: "aaa/bbb/ccc/ddd/hhh", Depth: 4, IsDir: false, Size: int64(len(content)), ModTime: testEntries["aaa/bbb/ccc/ddd/hhh"].ModTime}
 	callbacks := []base.SearchResult{
-		{Path: fileEntry.Path, LineNumber: 1, StartIndex: 0, EndIndex: 5, Line: "hello"},
-		{Path: fileEntry.Path, LineNumber: 2, StartIndex: 12, EndIndex: 17, Line: "second line hhhhh"},
+		{Path: fileEntry.Path, LineNumber: 1, Line: "hello", Matches: []base.Match{{StartIndex: 0, EndIndex: 5}}},
+		{Path: fileEntry.Path, LineNumber: 2, Line: "second line hhhhh", Matches: []base.Match{{StartIndex: 12, EndIndex: 17}}},
 	}
 	calledTimes := 0
-	err := scanner.ScanFile(fileEntry, regexp.MustCompile(`h\w{4}`), func(entry base.SearchResult) error {
+	err := scanner.ScanFile(fileEntry, matcher.NewRegexp(regexp.MustCompile(`h\w{4}`)), func(entry base.SearchResult) error {
 		if !reflect.DeepEqual(entry, callbacks[calledTimes]) {
 			t.Errorf("Callback called with %v", entry)
 		}
@@ -247,7 +292,7 @@ func TestLineScanner_ScanFile(t *testing.T) {
 	fileEntry = base.DirEntry{Path: "aaa/bbb/ccc/ddd", Depth: 3, IsDir: true, Size: 0, ModTime: testEntries["aaa/bbb/ccc/ddd"].ModTime}
 	callbacks = []base.SearchResult{}
 	calledTimes = 0
-	err = scanner.ScanFile(fileEntry, regexp.MustCompile(`h\w{4}`), func(entry base.SearchResult) error {
+	err = scanner.ScanFile(fileEntry, matcher.NewRegexp(regexp.MustCompile(`h\w{4}`)), func(entry base.SearchResult) error {
 		if !reflect.DeepEqual(entry, callbacks[calledTimes]) {
 			t.Errorf("Callback called with %v", entry)
 		}
@@ -261,14 +306,13 @@ func TestLineScanner_ScanFile(t *testing.T) {
 		t.Errorf("ScanDirs returned error %v", err)
 	}
 
-	// Skip item
+	// Skip item stops scanning the file
 	fileEntry = base.DirEntry{Path: "aaa/bbb/ccc/ddd/hhh", Depth: 4, IsDir: false, Size: int64(len(content)), ModTime: testEntries["aaa/bbb/ccc/ddd/hhh"].ModTime}
 	callbacks = []base.SearchResult{
-		{Path: fileEntry.Path, LineNumber: 1, StartIndex: 0, EndIndex: 5, Line: "hello"},
-		{Path: fileEntry.Path, LineNumber: 2, StartIndex: 12, EndIndex: 17, Line: "second line hhhhh"},
+		{Path: fileEntry.Path, LineNumber: 1, Line: "hello", Matches: []base.Match{{StartIndex: 0, EndIndex: 5}}},
 	}
 	calledTimes = 0
-	err = scanner.ScanFile(fileEntry, regexp.MustCompile(`h\w{4}`), func(entry base.SearchResult) error {
+	err = scanner.ScanFile(fileEntry, matcher.NewRegexp(regexp.MustCompile(`h\w{4}`)), func(entry base.SearchResult) error {
 		if !reflect.DeepEqual(entry, callbacks[calledTimes]) {
 			t.Errorf("Callback called with %v", entry)
 		}
@@ -285,10 +329,10 @@ func TestLineScanner_ScanFile(t *testing.T) {
 	// Skip all
 	fileEntry = base.DirEntry{Path: "aaa/bbb/ccc/ddd/hhh", Depth: 4, IsDir: false, Size: int64(len(content)), ModTime: testEntries["aaa/bbb/ccc/ddd/hhh"].ModTime}
 	callbacks = []base.SearchResult{
-		{Path: fileEntry.Path, LineNumber: 1, StartIndex: 0, EndIndex: 5, Line: "hello"},
+		{Path: fileEntry.Path, LineNumber: 1, Line: "hello", Matches: []base.Match{{StartIndex: 0, EndIndex: 5}}},
 	}
 	calledTimes = 0
-	err = scanner.ScanFile(fileEntry, regexp.MustCompile(`h\w{4}`), func(entry base.SearchResult) error {
+	err = scanner.ScanFile(fileEntry, matcher.NewRegexp(regexp.MustCompile(`h\w{4}`)), func(entry base.SearchResult) error {
 		if !reflect.DeepEqual(entry, callbacks[calledTimes]) {
 			t.Errorf("Callback called with %v", entry)
 		}
@@ -305,11 +349,11 @@ func TestLineScanner_ScanFile(t *testing.T) {
 	// Return error from callback
 	fileEntry = base.DirEntry{Path: "aaa/bbb/ccc/ddd/hhh", Depth: 4, IsDir: false, Size: int64(len(content)), ModTime: testEntries["aaa/bbb/ccc/ddd/hhh"].ModTime}
 	callbacks = []base.SearchResult{
-		{Path: fileEntry.Path, LineNumber: 1, StartIndex: 0, EndIndex: 5, Line: "hello"},
+		{Path: fileEntry.Path, LineNumber: 1, Line: "hello", Matches: []base.Match{{StartIndex: 0, EndIndex: 5}}},
 	}
 	calledTimes = 0
 	testError := errors.New("test")
-	err = scanner.ScanFile(fileEntry, regexp.MustCompile(`h\w{4}`), func(entry base.SearchResult) error {
+	err = scanner.ScanFile(fileEntry, matcher.NewRegexp(regexp.MustCompile(`h\w{4}`)), func(entry base.SearchResult) error {
 		if !reflect.DeepEqual(entry, callbacks[calledTimes]) {
 			t.Errorf("Callback called with %v", entry)
 		}
@@ -322,4 +366,288 @@ func TestLineScanner_ScanFile(t *testing.T) {
 	if err == nil || !errors.Is(err, testError) {
 		t.Errorf("ScanDirs returned error %v", err)
 	}
+
+	// Multiple matches in a line
+	fileEntry = base.DirEntry{Path: "aaa/bbb/ccc/ddd/hhh", Depth: 4, IsDir: false, Size: int64(len(content)), ModTime: testEntries["aaa/bbb/ccc/ddd/hhh"].ModTime}
+	callbacks = []base.SearchResult{
+		{Path: fileEntry.Path, LineNumber: 2, Line: "second line hhhhh", Matches: []base.Match{{StartIndex: 7, EndIndex: 11}, {StartIndex: 12, EndIndex: 14}, {StartIndex: 14, EndIndex: 16}}},
+		{Path: fileEntry.Path, LineNumber: 3, Line: "third line", Matches: []base.Match{{StartIndex: 1, EndIndex: 3}, {StartIndex: 6, EndIndex: 10}}},
+	}
+	calledTimes = 0
+	err = scanner.ScanFile(fileEntry, matcher.NewRegexp(regexp.MustCompile(`line|hh|hi`)), func(entry base.SearchResult) error {
+		if !reflect.DeepEqual(entry, callbacks[calledTimes]) {
+			t.Errorf("Callback called with %v", entry)
+		}
+		calledTimes++
+		return nil
+	})
+	if calledTimes != len(callbacks) {
+		t.Errorf("Callback called %v times", calledTimes)
+	}
+	if err != nil {
+		t.Errorf("ScanDirs returned error %v", err)
+	}
+}
+
+func TestLineScanner_ScanFile_Context(t *testing.T) {
+	now := time.Now().UTC()
+	content := "one\ntwo\nthree match\nfour\nfive match\nsix\nseven\neight\nnine\nten match"
+	testEntries := reader.MockEntries{
+		"aaa":     {ModTime: now, Content: nil},
+		"aaa/bbb": {ModTime: now, Content: &content},
+	}
+	reader := reader.NewMockReader(testEntries)
+	scanner := NewLine(reader, WithContext(1, 2))
+
+	fileEntry := base.DirEntry{Path: "aaa/bbb", Depth: 1, IsDir: false, Size: int64(len(content)), ModTime: now}
+	callbacks := []base.SearchResult{
+		{Path: fileEntry.Path, LineNumber: 2, Line: "two", IsContext: true},
+		{Path: fileEntry.Path, LineNumber: 3, Line: "three match", Matches: []base.Match{{StartIndex: 6, EndIndex: 11}}},
+		{Path: fileEntry.Path, LineNumber: 4, Line: "four", IsContext: true},
+		{Path: fileEntry.Path, LineNumber: 5, Line: "five match", Matches: []base.Match{{StartIndex: 5, EndIndex: 10}}},
+		{Path: fileEntry.Path, LineNumber: 6, Line: "six", IsContext: true},
+		{Path: fileEntry.Path, LineNumber: 7, Line: "seven", IsContext: true},
+		{Path: fileEntry.Path, LineNumber: 9, Line: "nine", IsContext: true},
+		{Path: fileEntry.Path, LineNumber: 10, Line: "ten match", Matches: []base.Match{{StartIndex: 4, EndIndex: 9}}},
+	}
+	calledTimes := 0
+	err := scanner.ScanFile(fileEntry, matcher.NewRegexp(regexp.MustCompile(`match`)), func(entry base.SearchResult) error {
+		if calledTimes >= len(callbacks) || !reflect.DeepEqual(entry, callbacks[calledTimes]) {
+			t.Errorf("Callback called with %v", entry)
+		}
+		calledTimes++
+		return nil
+	})
+	if calledTimes != len(callbacks) {
+		t.Errorf("Callback called %v times", calledTimes)
+	}
+	if err != nil {
+		t.Errorf("ScanFile returned error %v", err)
+	}
+}
+
+func TestLineScanner_ScanFile_Invert(t *testing.T) {
+	now := time.Now().UTC()
+	content := "one match\ntwo\nthree match\nfour"
+	testEntries := reader.MockEntries{
+		"aaa":     {ModTime: now, Content: nil},
+		"aaa/bbb": {ModTime: now, Content: &content},
+	}
+	reader := reader.NewMockReader(testEntries)
+	scanner := NewLine(reader, WithInvert(), WithContext(1, 0))
+
+	fileEntry := base.DirEntry{Path: "aaa/bbb", Depth: 1, IsDir: false, Size: int64(len(content)), ModTime: now}
+	callbacks := []base.SearchResult{
+		{Path: fileEntry.Path, LineNumber: 1, Line: "one match", IsContext: true},
+		{Path: fileEntry.Path, LineNumber: 2, Line: "two"},
+		{Path: fileEntry.Path, LineNumber: 3, Line: "three match", IsContext: true},
+		{Path: fileEntry.Path, LineNumber: 4, Line: "four"},
+	}
+	calledTimes := 0
+	err := scanner.ScanFile(fileEntry, matcher.NewRegexp(regexp.MustCompile(`match`)), func(entry base.SearchResult) error {
+		if calledTimes >= len(callbacks) || !reflect.DeepEqual(entry, callbacks[calledTimes]) {
+			t.Errorf("Callback called with %v", entry)
+		}
+		calledTimes++
+		return nil
+	})
+	if calledTimes != len(callbacks) {
+		t.Errorf("Callback called %v times", calledTimes)
+	}
+	if err != nil {
+		t.Errorf("ScanFile returned error %v", err)
+	}
+}
+
+func TestLineScanner_ScanFile_MultiLine(t *testing.T) {
+	now := time.Now().UTC()
+	content := "one\nfunc a() {\n\treturn nil\n}\ntwo\r\nfunc b() {\r\n\treturn nil\r\n}"
+	testEntries := reader.MockEntries{
+		"aaa":     {ModTime: now, Content: nil},
+		"aaa/bbb": {ModTime: now, Content: &content},
+	}
+	reader := reader.NewMockReader(testEntries)
+	re := matcher.NewRegexp(regexp.MustCompile(`func \w+\(\) \{\r?\n\s*return nil`))
+	fileEntry := base.DirEntry{Path: "aaa/bbb", Depth: 1, IsDir: false, Size: int64(len(content)), ModTime: now}
+
+	tests := []struct {
+		scanner   base.Scanner
+		callbacks []base.SearchResult
+	}{
+		{
+			NewLine(reader, WithMultiLine(1024), WithContext(1, 1)),
+			[]base.SearchResult{
+				{Path: fileEntry.Path, LineNumber: 1, Line: "one", IsContext: true},
+				{Path: fileEntry.Path, LineNumber: 2, EndLineNumber: 3, Line: "func a() {\n\treturn nil", Matches: []base.Match{{StartIndex: 0, EndIndex: 22}}},
+				{Path: fileEntry.Path, LineNumber: 4, Line: "}", IsContext: true},
+				{Path: fileEntry.Path, LineNumber: 5, Line: "two", IsContext: true},
+				{Path: fileEntry.Path, LineNumber: 6, EndLineNumber: 7, Line: "func b() {\r\n\treturn nil", Matches: []base.Match{{StartIndex: 0, EndIndex: 23}}},
+				{Path: fileEntry.Path, LineNumber: 8, Line: "}", IsContext: true},
+			},
+		},
+		{
+			NewLine(reader, WithMultiLine(1024), WithInvert()),
+			[]base.SearchResult{
+				{Path: fileEntry.Path, LineNumber: 1, Line: "one"},
+				{Path: fileEntry.Path, LineNumber: 4, Line: "}"},
+				{Path: fileEntry.Path, LineNumber: 5, Line: "two"},
+				{Path: fileEntry.Path, LineNumber: 8, Line: "}"},
+			},
+		},
+		{
+			NewLine(reader, WithMultiLine(10)),
+			[]base.SearchResult{},
+		},
+	}
+	for i, test := range tests {
+		calledTimes := 0
+		err := test.scanner.ScanFile(fileEntry, re, func(entry base.SearchResult) error {
+			if calledTimes >= len(test.callbacks) || !reflect.DeepEqual(entry, test.callbacks[calledTimes]) {
+				t.Errorf("Test %d: callback called with %v", i, entry)
+			}
+			calledTimes++
+			return nil
+		})
+		if calledTimes != len(test.callbacks) {
+			t.Errorf("Test %d: callback called %v times", i, calledTimes)
+		}
+		if (err != nil) != (len(test.callbacks) == 0) {
+			t.Errorf("Test %d: ScanFile returned error %v", i, err)
+		}
+	}
+}
+
+func TestLineScanner_ScanFile_LongLines(t *testing.T) {
+	now := time.Now().UTC()
+	content := "short\n" +
+		strings.Repeat("a", 200000) + "needle" + strings.Repeat("b", 100000) + "\n" +
+		"end needle\n" +
+		strings.Repeat("c", 65533) + "needle" + strings.Repeat("c", 70000) + "\r\n" +
+		strings.Repeat("d", 100000)
+	testEntries := reader.MockEntries{
+		"aaa":     {ModTime: now, Content: nil},
+		"aaa/bbb": {ModTime: now, Content: &content},
+	}
+	reader := reader.NewMockReader(testEntries)
+	scanner := NewLine(reader, WithMaxLength(10), WithContext(0, 1))
+
+	fileEntry := base.DirEntry{Path: "aaa/bbb", Depth: 1, IsDir: false, Size: int64(len(content)), ModTime: now}
+	callbacks := []base.SearchResult{
+		{Path: fileEntry.Path, LineNumber: 2, Line: "aaneedlebb", Matches: []base.Match{{StartIndex: 2, EndIndex: 8}}, Truncated: true, LineOffset: 199998, ColumnOffset: 199998},
+		{Path: fileEntry.Path, LineNumber: 3, Line: "end needle", Matches: []base.Match{{StartIndex: 4, EndIndex: 10}}},
+		{Path: fileEntry.Path, LineNumber: 4, Line: "ccneedlecc", Matches: []base.Match{{StartIndex: 2, EndIndex: 8}}, Truncated: true, LineOffset: 65531, ColumnOffset: 65531},
+		{Path: fileEntry.Path, LineNumber: 5, Line: "dddddddddd", IsContext: true, Truncated: true},
+	}
+	calledTimes := 0
+	err := scanner.ScanFile(fileEntry, matcher.NewRegexp(regexp.MustCompile(`needle`)), func(entry base.SearchResult) error {
+		if calledTimes >= len(callbacks) || !reflect.DeepEqual(entry, callbacks[calledTimes]) {
+			t.Errorf("Callback called with %v", entry)
+		}
+		calledTimes++
+		return nil
+	})
+	if calledTimes != len(callbacks) {
+		t.Errorf("Callback called %v times", calledTimes)
+	}
+	if err != nil {
+		t.Errorf("ScanFile returned error %v", err)
+	}
+}
+
+func TestLineScanner_ScanFile_LongLinesAnchored(t *testing.T) {
+	now := time.Now().UTC()
+	// Second window of the line starts right at foo
+	content := strings.Repeat("x", 32768) + "foo" + strings.Repeat("y", 70000) + "\n" +
+		strings.Repeat("x", 32767) + " foo" + strings.Repeat("y", 70000)
+	testEntries := reader.MockEntries{
+		"aaa":     {ModTime: now, Content: nil},
+		"aaa/bbb": {ModTime: now, Content: &content},
+	}
+	scanner := NewLine(reader.NewMockReader(testEntries), WithMaxLength(10))
+	fileEntry := base.DirEntry{Path: "aaa/bbb", Depth: 1, IsDir: false, Size: int64(len(content)), ModTime: now}
+	tests := []struct {
+		pattern string
+		lines   []int
+	}{
+		{`^foo`, nil},
+		{`\Afoo`, nil},
+		{`\bfoo`, []int{2}},
+		{`foo`, []int{1, 2}},
+	}
+	for _, test := range tests {
+		var lines []int
+		err := scanner.ScanFile(fileEntry, matcher.NewRegexp(regexp.MustCompile(test.pattern)), func(result base.SearchResult) error {
+			lines = append(lines, result.LineNumber)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("Pattern %s: ScanFile returned error %v", test.pattern, err)
+		}
+		if !reflect.DeepEqual(lines, test.lines) {
+			t.Errorf("Pattern %s: matched lines %v expected %v", test.pattern, lines, test.lines)
+		}
+	}
+}
+
+func TestLineScanner_ScanFile_Binary(t *testing.T) {
+	now := time.Now().UTC()
+	content := "text\nbinary\x00 match\nmatch again"
+	testEntries := reader.MockEntries{
+		"aaa":     {ModTime: now, Content: nil},
+		"aaa/bbb": {ModTime: now, Content: &content},
+	}
+	fileEntry := base.DirEntry{Path: "aaa/bbb", Depth: 1, IsDir: false, Size: int64(len(content)), ModTime: now}
+	tests := []struct {
+		mode      BinaryMode
+		callbacks []base.SearchResult
+	}{
+		{BinaryMatches, []base.SearchResult{
+			{Path: fileEntry.Path, LineNumber: 2, IsBinary: true},
+		}},
+		{BinarySkip, []base.SearchResult{}},
+		{BinaryText, []base.SearchResult{
+			{Path: fileEntry.Path, LineNumber: 2, Line: "binary\x00 match", Matches: []base.Match{{StartIndex: 8, EndIndex: 13}}},
+			{Path: fileEntry.Path, LineNumber: 3, Line: "match again", Matches: []base.Match{{StartIndex: 0, EndIndex: 5}}},
+		}},
+	}
+	for _, test := range tests {
+		scanner := NewLine(reader.NewMockReader(testEntries), WithBinaryMode(test.mode))
+		calledTimes := 0
+		err := scanner.ScanFile(fileEntry, matcher.NewRegexp(regexp.MustCompile(`match`)), func(entry base.SearchResult) error {
+			if calledTimes >= len(test.callbacks) || !reflect.DeepEqual(entry, test.callbacks[calledTimes]) {
+				t.Errorf("Mode %v: callback called with %v", test.mode, entry)
+			}
+			calledTimes++
+			return nil
+		})
+		if calledTimes != len(test.callbacks) {
+			t.Errorf("Mode %v: callback called %v times", test.mode, calledTimes)
+		}
+		// Skipped file is told from a scanned file that has no matches
+		if (test.mode == BinarySkip) != errors.Is(err, base.ErrSkipItem) || (err != nil && !errors.Is(err, base.ErrSkipItem)) {
+			t.Errorf("Mode %v: ScanFile returned error %v", test.mode, err)
+		}
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	tests := []struct {
+		block  string
+		cut    bool
+		binary bool
+	}{
+		{"", false, false},
+		{"plain text\n", false, false},
+		{"текст", false, false},
+		{"nul\x00byte", false, true},
+		{"\xff\xfe\xfd\xfc", false, true},
+		{"mostly valid text with one \xff byte", false, false},
+		{"cut rune \xd1", true, false},
+		{"\xd1", false, true},
+	}
+	for _, test := range tests {
+		if binary := isBinary([]byte(test.block), test.cut); binary != test.binary {
+			t.Errorf("isBinary(%q, %v) returned %v", test.block, test.cut, binary)
+		}
+	}
 }
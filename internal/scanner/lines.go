@@ -0,0 +1,169 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"unicode/utf8"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+// Line read by lineReader
+type readLine struct {
+	text      []byte       // whole line without line ending or its part around the first match
+	matches   []base.Match // matches in text
+	matched   bool         // whether the line has a match. Matches may be cut from text of a long line
+	truncated bool         // whether text is a part of the line
+	offset    int          // byte offset of text in the line
+	column    int          // rune offset of text in the line
+}
+
+// Reads lines of any length in bounded memory.
+// Lines that do not fit into the buffer of the reader are scanned in windows
+// that overlap by half of the buffer, so matches shorter than that are not missed.
+// Every window after the first starts with a rune of context before the overlap, which is not searched itself,
+// so assertions like ^ and \b do not match at the boundary of a window
+type lineReader struct {
+	reader *bufio.Reader
+	window []byte // part of a long line that is scanned
+}
+
+// Reads the next line and finds matches in it.
+// Text of a line that fits into the buffer is valid until the next call.
+// Returns io.EOF when there are no more lines
+func (r *lineReader) next(find func(text []byte) []base.Match) (readLine, error) {
+	chunk, err := r.reader.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return r.nextLong(chunk, find)
+	}
+	if err != nil && (!errors.Is(err, io.EOF) || len(chunk) == 0) {
+		return readLine{}, err
+	}
+	text := trimLineEnding(chunk)
+	matches := find(text)
+	return readLine{text: text, matches: matches, matched: matches != nil}, nil
+}
+
+// Scans a line that does not fit into the buffer starting with its first chunk.
+// Text is a copy of the window that has the first match or the first window when nothing matches
+func (r *lineReader) nextLong(chunk []byte, find func(text []byte) []base.Match) (readLine, error) {
+	overlap := r.reader.Size() / 2
+	r.window = append(r.window[:0], chunk...)
+	line := readLine{truncated: true}
+	windowOffset := 0 // byte offset of the window in the line
+	windowColumn := 0 // rune offset of the window in the line
+	contextEnd := 0   // end of the context at the start of the window. Matches that start in it are not accepted
+	acceptedEnd := 0  // end offset of the last accepted match in the line
+	ended := false
+	for {
+		text := r.window
+		if ended {
+			text = trimLineEnding(text)
+		}
+		// Matches that start in the tail are found again in the next window
+		tailStart := len(text)
+		if !ended {
+			tailStart = len(text) - overlap
+			for tailStart > 0 && !utf8.RuneStart(text[tailStart]) {
+				tailStart--
+			}
+		}
+		for _, match := range find(text) {
+			if match.StartIndex < contextEnd || windowOffset+match.StartIndex < acceptedEnd || match.StartIndex >= tailStart {
+				continue
+			}
+			acceptedEnd = windowOffset + match.EndIndex
+			if !line.matched {
+				line.matched = true
+				line.text = nil
+				line.matches = nil
+			}
+			if line.text == nil {
+				line.matches = append(line.matches, match)
+			}
+		}
+		if line.text == nil {
+			line.text = bytes.Clone(text)
+			line.offset = windowOffset
+			line.column = windowColumn
+		}
+		if ended {
+			return line, nil
+		}
+
+		contextStart := tailStart
+		if contextStart > 0 {
+			_, size := utf8.DecodeLastRune(text[:contextStart])
+			contextStart -= size
+		}
+		contextEnd = tailStart - contextStart
+		windowColumn += utf8.RuneCount(text[:contextStart])
+		windowOffset += contextStart
+		r.window = append(r.window[:0], r.window[contextStart:]...)
+		chunk, err := r.reader.ReadSlice('\n')
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) && !errors.Is(err, io.EOF) {
+			return readLine{}, err
+		}
+		r.window = append(r.window, chunk...)
+		ended = !errors.Is(err, bufio.ErrBufferFull)
+	}
+}
+
+// Removes line ending the same way bufio.ScanLines does
+func trimLineEnding(line []byte) []byte {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	return bytes.TrimSuffix(line, []byte("\r"))
+}
+
+// Cuts line of a result to at most maxLength runes around the first match.
+// Keeps matches that are at least partially in the cut line
+func truncateResult(result *base.SearchResult, maxLength int) {
+	line := result.Line
+	if utf8.RuneCountInString(line) <= maxLength {
+		return
+	}
+	start, end := 0, 0
+	if len(result.Matches) > 0 {
+		start, end = result.Matches[0].StartIndex, result.Matches[0].EndIndex
+	}
+	budget := maxLength - utf8.RuneCountInString(line[start:end])
+	if budget <= 0 {
+		// Match itself is too long
+		end = start
+		budget = maxLength
+	} else {
+		// Half of the rest goes before the match
+		for before := budget / 2; before > 0 && start > 0; before-- {
+			_, size := utf8.DecodeLastRuneInString(line[:start])
+			start -= size
+			budget--
+		}
+	}
+	for ; budget > 0 && end < len(line); budget-- {
+		_, size := utf8.DecodeRuneInString(line[end:])
+		end += size
+	}
+	for ; budget > 0 && start > 0; budget-- {
+		_, size := utf8.DecodeLastRuneInString(line[:start])
+		start -= size
+	}
+
+	var matches []base.Match
+	for _, match := range result.Matches {
+		if match.StartIndex >= end || (match.EndIndex <= start && match.EndIndex > match.StartIndex) {
+			continue
+		}
+		matches = append(matches, base.Match{
+			StartIndex: max(match.StartIndex, start) - start,
+			EndIndex:   min(match.EndIndex, end) - start,
+			Pattern:    match.Pattern,
+		})
+	}
+	result.Line = line[start:end]
+	result.Matches = matches
+	result.Truncated = true
+	result.LineOffset += start
+	result.ColumnOffset += utf8.RuneCountInString(line[:start])
+}
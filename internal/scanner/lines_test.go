@@ -0,0 +1,42 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+func TestTruncateResult(t *testing.T) {
+	tests := []struct {
+		result   base.SearchResult
+		expected base.SearchResult
+	}{
+		{
+			base.SearchResult{Line: "short", Matches: []base.Match{{StartIndex: 0, EndIndex: 1}}},
+			base.SearchResult{Line: "short", Matches: []base.Match{{StartIndex: 0, EndIndex: 1}}},
+		},
+		{
+			base.SearchResult{Line: "тест match тест", Matches: []base.Match{{StartIndex: 9, EndIndex: 14}}},
+			base.SearchResult{Line: "т match т", Matches: []base.Match{{StartIndex: 3, EndIndex: 8}}, Truncated: true, LineOffset: 6, ColumnOffset: 3},
+		},
+		{
+			base.SearchResult{Line: "match and more", Matches: []base.Match{{StartIndex: 0, EndIndex: 5}, {StartIndex: 10, EndIndex: 14}}},
+			base.SearchResult{Line: "match and", Matches: []base.Match{{StartIndex: 0, EndIndex: 5}}, Truncated: true},
+		},
+		{
+			base.SearchResult{Line: "a very long match", Matches: []base.Match{{StartIndex: 2, EndIndex: 17}}},
+			base.SearchResult{Line: "very long", Matches: []base.Match{{StartIndex: 0, EndIndex: 9}}, Truncated: true, LineOffset: 2, ColumnOffset: 2},
+		},
+		{
+			base.SearchResult{Line: "context line", IsContext: true},
+			base.SearchResult{Line: "context l", IsContext: true, Truncated: true},
+		},
+	}
+	for i, test := range tests {
+		truncateResult(&test.result, 9)
+		if !reflect.DeepEqual(test.result, test.expected) {
+			t.Errorf("Test %d: truncateResult returned %v", i, test.result)
+		}
+	}
+}
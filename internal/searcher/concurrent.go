@@ -2,8 +2,9 @@ package searcher
 
 import (
 	"context"
+	"errors"
+	"iter"
 	"log"
-	"regexp"
 	"sync"
 
 	"github.com/pi-kei/mgrep/internal/base"
@@ -14,22 +15,61 @@ type Concurrent struct {
 	filter      base.Filter
 	sink        base.Sink
 	logger      *log.Logger
-	concurrency int // number of goroutines to spawn
-	bufferSize  int // size of buffers of channels
+	concurrency int  // number of goroutines to spawn
+	bufferSize  int  // size of buffers of channels
+	ordered     bool // whether results must be handled in the same order as in Serial
+	firstMatch  bool // whether scanning a file stops after its first result that has matches
 }
 
-func NewConcurrent(scanner base.Scanner, filter base.Filter, sink base.Sink, logger *log.Logger, concurrency int, bufferSize int) base.Searcher {
-	return &Concurrent{scanner, filter, sink, logger, concurrency, bufferSize}
+// Item passed from file goroutines to the goroutine that handles results
+type fileResult struct {
+	result  base.SearchResult
+	done    bool   // whether the file is scanned. result is not set then
+	path    string // path of the scanned file. Set when done
+	matches int    // number of results of the file that have matches. Set when done
+	failed  bool   // whether the file failed to scan or was skipped by the scanner. Set when done
 }
 
-func (c *Concurrent) Search(ctx context.Context, rootPath string, searchRegexp *regexp.Regexp) {
+type ConcurrentOption func(*Concurrent)
+
+// Makes searcher handle results in directory walk order, the same way Serial does.
+// Directories are walked by a single goroutine then, files are still scanned concurrently.
+// At most as many files as goroutines are scanned or wait to be handled at once, so memory stays bounded
+func WithOrdered() ConcurrentOption {
+	return func(c *Concurrent) {
+		c.ordered = true
+	}
+}
+
+// Stops scanning a file after its first result that has matches.
+// Whether a file has matches is known then and the rest of it is not read
+func WithConcurrentFirstMatch() ConcurrentOption {
+	return func(c *Concurrent) {
+		c.firstMatch = true
+	}
+}
+
+func NewConcurrent(scanner base.Scanner, filter base.Filter, sink base.Sink, logger *log.Logger, concurrency int, bufferSize int, options ...ConcurrentOption) base.Searcher {
+	searcher := Concurrent{scanner: scanner, filter: filter, sink: sink, logger: logger, concurrency: concurrency, bufferSize: bufferSize}
+	for _, option := range options {
+		option(&searcher)
+	}
+	return &searcher
+}
+
+func (c *Concurrent) Search(ctx context.Context, rootPath string, matcher base.Matcher) base.SearchSummary {
+	if c.ordered {
+		return c.searchOrdered(ctx, rootPath, matcher)
+	}
+
+	var summary summaryCollector
 	type pathAndDepth struct {
 		path  string
 		depth int
 	}
 	pathsChannel := make(chan pathAndDepth, c.bufferSize)
 	filesChannel := make(chan base.DirEntry, c.bufferSize)
-	resultsChannel := make(chan base.SearchResult, c.bufferSize)
+	resultsChannel := make(chan fileResult, c.bufferSize)
 
 	dirsConcurr := 1
 	filesConcurr := 1
@@ -44,50 +84,53 @@ func (c *Concurrent) Search(ctx context.Context, rootPath string, searchRegexp *
 	for i := 0; i < dirsConcurr; i++ {
 		go func(index int) {
 			defer dirsWG.Done()
-			for {
-				select {
-				case newRootPath, ok := <-pathsChannel:
-					if !ok {
-						return
-					}
-					err := c.scanner.ScanDirs(newRootPath.path, newRootPath.depth, func(entry base.DirEntry) error {
-						if entry.IsDir {
-							if c.filter.SkipDirEntry(entry) {
-								return base.ErrSkipItem
-							}
-							if entry.Path == newRootPath.path {
-								return nil
-							}
-							pathsWG.Add(1)
-							select {
-							case pathsChannel <- pathAndDepth{entry.Path, entry.Depth}:
-								return base.ErrSkipItem
-							case <-ctx.Done():
-								pathsWG.Add(-1)
-								return base.ErrSkipAll
-							default:
-								pathsWG.Add(-1)
-								return nil
-							}
-						}
-
-						if c.filter.SkipFileEntry(entry) {
+			// Paths are received until the channel is closed, even after cancelling,
+			// so every added path is done and the channel gets closed
+			for newRootPath := range pathsChannel {
+				if ctx.Err() != nil {
+					pathsWG.Done()
+					continue
+				}
+				err := c.scanner.ScanDirs(newRootPath.path, newRootPath.depth, func(entry base.DirEntry) error {
+					if entry.IsDir {
+						if c.filter.SkipDirEntry(entry) {
 							return base.ErrSkipItem
 						}
-						select {
-						case filesChannel <- entry:
+						if entry.Path == newRootPath.path {
 							return nil
+						}
+						pathsWG.Add(1)
+						select {
+						case pathsChannel <- pathAndDepth{entry.Path, entry.Depth}:
+							return base.ErrSkipItem
 						case <-ctx.Done():
+							pathsWG.Add(-1)
 							return base.ErrSkipAll
+						default:
+							pathsWG.Add(-1)
+							return nil
 						}
-					})
-					if err != nil {
-						c.logger.Println("Error scanning dir", err)
 					}
-					pathsWG.Done()
-				case <-ctx.Done():
-					return
+
+					if c.filter.SkipFileEntry(entry) {
+						return base.ErrSkipItem
+					}
+					select {
+					case filesChannel <- entry:
+						return nil
+					case <-ctx.Done():
+						return base.ErrSkipAll
+					}
+				}, func(warning *base.Warning) {
+					reportWarning(c.logger, c.sink, &summary, warning)
+				})
+				if err != nil && newRootPath.depth > 0 {
+					// Subdirectory is scanned as a root here, its failure is a warning as in serial mode
+					reportWarning(c.logger, c.sink, &summary, &base.Warning{Path: newRootPath.path, Err: err})
+				} else if err != nil {
+					reportError(c.logger, c.sink, &summary, "Error scanning dir", err)
 				}
+				pathsWG.Done()
 			}
 		}(i)
 	}
@@ -107,19 +150,25 @@ func (c *Concurrent) Search(ctx context.Context, rootPath string, searchRegexp *
 					if !ok {
 						return
 					}
-					err := c.scanner.ScanFile(fileEntry, searchRegexp, func(sr base.SearchResult) error {
+					matches := 0
+					err := c.scanner.ScanFile(fileEntry, matcher, func(sr base.SearchResult) error {
 						if c.filter.SkipSearchResult(sr) {
-							return base.ErrSkipItem
+							return nil
 						}
 						select {
-						case resultsChannel <- sr:
-							return nil
+						case resultsChannel <- fileResult{result: sr}:
+							return c.countMatch(sr, &matches)
 						case <-ctx.Done():
 							return base.ErrSkipAll
 						}
 					})
-					if err != nil {
-						c.logger.Println("Error scanning file", err)
+					if err != nil && !errors.Is(err, base.ErrSkipItem) {
+						reportError(c.logger, c.sink, &summary, "Error scanning file", err)
+					}
+					select {
+					case resultsChannel <- fileResult{done: true, path: fileEntry.Path, matches: matches, failed: err != nil}:
+					case <-ctx.Done():
+						return
 					}
 				case <-ctx.Done():
 					return
@@ -139,7 +188,171 @@ func (c *Concurrent) Search(ctx context.Context, rootPath string, searchRegexp *
 		pathsWG.Wait()
 	}()
 
-	for result := range resultsChannel {
-		c.sink.HandleResult(result)
+	for item := range resultsChannel {
+		c.handle(ctx, item, &summary)
+	}
+	return summary.get()
+}
+
+// Sink of the searcher is not used by the iterator
+func (c *Concurrent) Results(ctx context.Context, rootPath string, matcher base.Matcher) (iter.Seq2[base.SearchResult, error], *base.SearchSummary) {
+	summary := &base.SearchSummary{}
+	return streamResults(ctx, summary, func(ctx context.Context, sink base.Sink) base.SearchSummary {
+		searcher := *c
+		searcher.sink = sink
+		return searcher.Search(ctx, rootPath, matcher)
+	}), summary
+}
+
+// Counts a sent result of a file.
+// Returns SkipItem after the first result that has matches when only it is needed
+func (c *Concurrent) countMatch(result base.SearchResult, matches *int) error {
+	if result.IsContext {
+		return nil
+	}
+	*matches++
+	if c.firstMatch {
+		return base.ErrSkipItem
+	}
+	return nil
+}
+
+// Passes an item to the sink
+func (c *Concurrent) handle(ctx context.Context, item fileResult, summary *summaryCollector) {
+	if !item.done {
+		c.sink.HandleResult(item.result)
+		summary.addResult(item.result)
+		return
+	}
+	if !item.failed && ctx.Err() == nil {
+		c.sink.HandleFileEnd(item.path, item.matches)
+	}
+}
+
+func (c *Concurrent) searchOrdered(ctx context.Context, rootPath string, matcher base.Matcher) base.SearchSummary {
+	var summary summaryCollector
+	type indexedEntry struct {
+		index int // position of a file in walk order
+		entry base.DirEntry
+	}
+	type indexedResult struct {
+		index int // position of a file in walk order
+		fileResult
+	}
+	filesConcurr := max(c.concurrency, 1)
+	filesChannel := make(chan indexedEntry, c.bufferSize)
+	resultsChannel := make(chan indexedResult, c.bufferSize)
+	// Slot is taken by a file when it is walked and freed when its results are handled,
+	// so results of a limited number of files wait for a slow file that is before them
+	slots := make(chan struct{}, filesConcurr)
+
+	go func() {
+		defer close(filesChannel)
+		index := 0
+		err := c.scanner.ScanDirs(rootPath, 0, func(entry base.DirEntry) error {
+			if entry.IsDir {
+				if c.filter.SkipDirEntry(entry) {
+					return base.ErrSkipItem
+				}
+				return nil
+			}
+
+			if c.filter.SkipFileEntry(entry) {
+				return base.ErrSkipItem
+			}
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return base.ErrSkipAll
+			}
+			select {
+			case filesChannel <- indexedEntry{index, entry}:
+				index++
+				return nil
+			case <-ctx.Done():
+				return base.ErrSkipAll
+			}
+		}, func(warning *base.Warning) {
+			reportWarning(c.logger, c.sink, &summary, warning)
+		})
+		if err != nil {
+			reportError(c.logger, c.sink, &summary, "Error scanning dir", err)
+		}
+	}()
+
+	var filesWG sync.WaitGroup
+	filesWG.Add(filesConcurr)
+	for i := 0; i < filesConcurr; i++ {
+		go func() {
+			defer filesWG.Done()
+			for {
+				select {
+				case file, ok := <-filesChannel:
+					if !ok {
+						return
+					}
+					matches := 0
+					err := c.scanner.ScanFile(file.entry, matcher, func(sr base.SearchResult) error {
+						if c.filter.SkipSearchResult(sr) {
+							return nil
+						}
+						select {
+						case resultsChannel <- indexedResult{file.index, fileResult{result: sr}}:
+							return c.countMatch(sr, &matches)
+						case <-ctx.Done():
+							return base.ErrSkipAll
+						}
+					})
+					if err != nil && !errors.Is(err, base.ErrSkipItem) {
+						reportError(c.logger, c.sink, &summary, "Error scanning file", err)
+					}
+					select {
+					case resultsChannel <- indexedResult{file.index, fileResult{done: true, path: file.entry.Path, matches: matches, failed: err != nil}}:
+					case <-ctx.Done():
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(resultsChannel)
+		filesWG.Wait()
+	}()
+
+	// Results of the next file in walk order are handled right away,
+	// results of other files are buffered until all previous files are done
+	next := 0
+	pending := make(map[int][]indexedResult)
+	for item := range resultsChannel {
+		if item.index != next {
+			pending[item.index] = append(pending[item.index], item)
+			continue
+		}
+		c.handle(ctx, item.fileResult, &summary)
+		if !item.done {
+			continue
+		}
+		<-slots
+		next++
+		for buffered, ok := pending[next]; ok; buffered, ok = pending[next] {
+			delete(pending, next)
+			done := false
+			for _, bufferedItem := range buffered {
+				c.handle(ctx, bufferedItem.fileResult, &summary)
+				if bufferedItem.done {
+					<-slots
+					done = true
+					break
+				}
+			}
+			if !done {
+				break
+			}
+			next++
+		}
 	}
+	return summary.get()
 }
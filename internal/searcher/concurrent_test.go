@@ -2,18 +2,95 @@ package searcher
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"log"
 	"regexp"
 	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/pi-kei/mgrep/internal/base"
 	"github.com/pi-kei/mgrep/internal/filter"
+	"github.com/pi-kei/mgrep/internal/matcher"
 	"github.com/pi-kei/mgrep/internal/reader"
 	"github.com/pi-kei/mgrep/internal/scanner"
 	"github.com/pi-kei/mgrep/internal/sink"
 )
 
+func TestConcurrentSearcher_Ordered(t *testing.T) {
+	for readerName, newReader := range testReaders {
+		for _, seed := range []int64{103, 10, 18} {
+			entries, rootName, _ := reader.NewEntriesGen(seed, 20, 4, 5, 7, time.Now().UTC(), 48).Generate()
+			scanner := scanner.NewLine(newReader(entries), scanner.WithContext(1, 1))
+			filter := filter.NewNoop()
+			re := matcher.NewRegexp(regexp.MustCompile("and|is"))
+
+			var serialOut strings.Builder
+			NewSerial(scanner, filter, sink.NewWriter(&serialOut), log.Default()).Search(context.Background(), rootName, re)
+			var concurrentOut strings.Builder
+			NewConcurrent(scanner, filter, sink.NewWriter(&concurrentOut), log.Default(), 4, 2, WithOrdered()).Search(context.Background(), rootName, re)
+
+			if serialOut.Len() == 0 {
+				t.Errorf("Seed %v over %s: no results", seed, readerName)
+			}
+			if concurrentOut.String() != serialOut.String() {
+				t.Errorf("Seed %v over %s: concurrent output differs from serial output", seed, readerName)
+			}
+		}
+	}
+}
+
+func TestConcurrentSearcher_OrderedBound(t *testing.T) {
+	now := time.Now().UTC()
+	content := "match"
+	testEntries := reader.MockEntries{"aaa": {ModTime: now}}
+	for i := 0; i < 20; i++ {
+		testEntries[fmt.Sprintf("aaa/%02d", i)] = reader.MockEntry{ModTime: now, Content: &content}
+	}
+	slowReader := &blockingReader{Reader: reader.NewMockReader(testEntries), path: "aaa/00", opened: make(chan struct{}), release: make(chan struct{})}
+	scanner := scanner.NewLine(slowReader)
+	var out strings.Builder
+	done := make(chan struct{})
+	go func() {
+		NewConcurrent(scanner, filter.NewNoop(), sink.NewFiles(&out), log.Default(), 4, 0, WithOrdered()).Search(context.Background(), "aaa", matcher.NewRegexp(regexp.MustCompile("match")))
+		close(done)
+	}()
+
+	// Files after the slow one wait for it instead of being scanned and buffered
+	<-slowReader.opened
+	time.Sleep(50 * time.Millisecond)
+	if others := slowReader.others.Load(); others != 3 {
+		t.Errorf("Files opened while the first one is scanned: %v", others)
+	}
+	close(slowReader.release)
+	<-done
+	if lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"); len(lines) != 20 || lines[0] != "aaa/00" || lines[19] != "aaa/19" {
+		t.Errorf("Output %q", out.String())
+	}
+}
+
+// Reader that blocks opening of a file until it is released and counts other opened files
+type blockingReader struct {
+	base.Reader
+	path    string
+	opened  chan struct{}
+	release chan struct{}
+	others  atomic.Int32
+}
+
+func (r *blockingReader) OpenFile(fileEntry base.DirEntry) (io.ReadCloser, error) {
+	if fileEntry.Path == r.path {
+		close(r.opened)
+		<-r.release
+	} else {
+		r.others.Add(1)
+	}
+	return r.Reader.OpenFile(fileEntry)
+}
+
 func BenchmarkConcurrentSearcher(b *testing.B) {
 	b.Run("1", func(b *testing.B) {
 		benchmarkConcurrentSearcher(b, 103, 5, 2, 4, 4)
@@ -53,7 +130,7 @@ func benchmarkConcurrentSearcher(b *testing.B, seed int64, maxLines, maxDepth, m
 	sink := sink.NewNoop()
 	searcher := NewConcurrent(scanner, filter, sink, log.Default(), runtime.NumCPU(), 1024)
 	ctx := context.Background()
-	re := regexp.MustCompile("and")
+	re := matcher.NewRegexp(regexp.MustCompile("and"))
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -2,24 +2,41 @@ package searcher
 
 import (
 	"context"
+	"errors"
+	"iter"
 	"log"
-	"regexp"
 
 	"github.com/pi-kei/mgrep/internal/base"
 )
 
 type Serial struct {
-	scanner base.Scanner
-	filter  base.Filter
-	sink    base.Sink
-	logger  *log.Logger
+	scanner    base.Scanner
+	filter     base.Filter
+	sink       base.Sink
+	logger     *log.Logger
+	firstMatch bool // whether scanning a file stops after its first result that has matches
 }
 
-func NewSerial(scanner base.Scanner, filter base.Filter, sink base.Sink, logger *log.Logger) base.Searcher {
-	return &Serial{scanner, filter, sink, logger}
+type SerialOption func(*Serial)
+
+// Stops scanning a file after its first result that has matches.
+// Whether a file has matches is known then and the rest of it is not read
+func WithSerialFirstMatch() SerialOption {
+	return func(s *Serial) {
+		s.firstMatch = true
+	}
+}
+
+func NewSerial(scanner base.Scanner, filter base.Filter, sink base.Sink, logger *log.Logger, options ...SerialOption) base.Searcher {
+	searcher := Serial{scanner: scanner, filter: filter, sink: sink, logger: logger}
+	for _, option := range options {
+		option(&searcher)
+	}
+	return &searcher
 }
 
-func (s *Serial) Search(ctx context.Context, rootPath string, searchRegexp *regexp.Regexp) {
+func (s *Serial) Search(ctx context.Context, rootPath string, matcher base.Matcher) base.SearchSummary {
+	var summary summaryCollector
 	done := make(chan struct{})
 
 	go func() {
@@ -40,7 +57,8 @@ func (s *Serial) Search(ctx context.Context, rootPath string, searchRegexp *rege
 			if s.filter.SkipFileEntry(entry) {
 				return base.ErrSkipItem
 			}
-			err := s.scanner.ScanFile(entry, searchRegexp, func(result base.SearchResult) error {
+			matches := 0
+			err := s.scanner.ScanFile(entry, matcher, func(result base.SearchResult) error {
 				select {
 				case <-ctx.Done():
 					return base.ErrSkipAll
@@ -48,21 +66,48 @@ func (s *Serial) Search(ctx context.Context, rootPath string, searchRegexp *rege
 				}
 
 				if s.filter.SkipSearchResult(result) {
-					return base.ErrSkipItem
+					return nil
 				}
 				s.sink.HandleResult(result)
+				summary.addResult(result)
+				if !result.IsContext {
+					matches++
+					if s.firstMatch {
+						return base.ErrSkipItem
+					}
+				}
 				return nil
 			})
 			if err != nil {
-				s.logger.Println("Error scanning file", err)
+				// File that is skipped by the scanner is not scanned, so it has no end
+				if !errors.Is(err, base.ErrSkipItem) {
+					reportError(s.logger, s.sink, &summary, "Error scanning file", err)
+				}
+				return nil
+			}
+			if ctx.Err() == nil {
+				s.sink.HandleFileEnd(entry.Path, matches)
 			}
 			return nil
+		}, func(warning *base.Warning) {
+			reportWarning(s.logger, s.sink, &summary, warning)
 		})
 		if err != nil {
-			s.logger.Println("Error scanning dir", err)
+			reportError(s.logger, s.sink, &summary, "Error scanning dir", err)
 		}
 		done <- struct{}{}
 	}()
 
 	<-done
+	return summary.get()
+}
+
+// Sink of the searcher is not used by the iterator
+func (s *Serial) Results(ctx context.Context, rootPath string, matcher base.Matcher) (iter.Seq2[base.SearchResult, error], *base.SearchSummary) {
+	summary := &base.SearchSummary{}
+	return streamResults(ctx, summary, func(ctx context.Context, sink base.Sink) base.SearchSummary {
+		searcher := *s
+		searcher.sink = sink
+		return searcher.Search(ctx, rootPath, matcher)
+	}), summary
 }
@@ -2,17 +2,133 @@ package searcher
 
 import (
 	"context"
+	"errors"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/pi-kei/mgrep/internal/base"
 	"github.com/pi-kei/mgrep/internal/filter"
+	"github.com/pi-kei/mgrep/internal/matcher"
 	"github.com/pi-kei/mgrep/internal/reader"
 	"github.com/pi-kei/mgrep/internal/scanner"
 	"github.com/pi-kei/mgrep/internal/sink"
 )
 
+// Readers that searchers are tested with
+var testReaders = map[string]func(reader.MockEntries) base.Reader{
+	"mock": reader.NewMockReader,
+	"fs":   reader.NewMockFS,
+}
+
+func TestSearchers_Summary(t *testing.T) {
+	now := time.Now().UTC()
+	content := "match\nno\nmatch match"
+	testError := errors.New("test")
+	testEntries := reader.MockEntries{
+		"aaa":     {ModTime: now},
+		"aaa/bbb": {ModTime: now, Content: &content},
+		"aaa/ccc": {ModTime: now, Content: &content},
+		"aaa/ddd": {ModTime: now, Content: &content, Err: testError},
+	}
+	logger := log.New(io.Discard, "", 0)
+	for readerName, newReader := range testReaders {
+		scanner := scanner.NewLine(newReader(testEntries), scanner.WithContext(1, 1))
+		searchers := map[string]base.Searcher{
+			"serial":             NewSerial(scanner, filter.NewNoop(), sink.NewNoop(), logger),
+			"concurrent":         NewConcurrent(scanner, filter.NewNoop(), sink.NewNoop(), logger, 4, 0),
+			"concurrent ordered": NewConcurrent(scanner, filter.NewNoop(), sink.NewNoop(), logger, 4, 0, WithOrdered()),
+		}
+		for name, searcher := range searchers {
+			summary := searcher.Search(context.Background(), "aaa", matcher.NewRegexp(regexp.MustCompile("match")))
+			if summary.Matches != 4 || summary.Errors != 1 || !errors.Is(summary.Err, testError) {
+				t.Errorf("%s over %s: Search returned %v", name, readerName, summary)
+			}
+			summary = searcher.Search(context.Background(), "aaa/bbb", matcher.NewRegexp(regexp.MustCompile("nothing")))
+			if summary.Matches != 0 || summary.Errors != 0 || summary.Err != nil {
+				t.Errorf("%s over %s: Search returned %v", name, readerName, summary)
+			}
+		}
+	}
+}
+
+func TestSearchers_FileEnd(t *testing.T) {
+	now := time.Now().UTC()
+	content := "match\nno\nmatch match"
+	otherContent := "no"
+	testEntries := reader.MockEntries{
+		"aaa":     {ModTime: now},
+		"aaa/bbb": {ModTime: now, Content: &content},
+		"aaa/ccc": {ModTime: now, Content: &otherContent},
+		"aaa/ddd": {ModTime: now, Content: &content},
+	}
+	logger := log.New(io.Discard, "", 0)
+	for readerName, newReader := range testReaders {
+		scanner := scanner.NewLine(newReader(testEntries))
+		for _, firstMatch := range []bool{false, true} {
+			var serialOptions []SerialOption
+			var concurrentOptions []ConcurrentOption
+			if firstMatch {
+				serialOptions = append(serialOptions, WithSerialFirstMatch())
+				concurrentOptions = append(concurrentOptions, WithConcurrentFirstMatch())
+			}
+			var serialOut, concurrentOut, orderedOut strings.Builder
+			searchers := map[*strings.Builder]base.Searcher{
+				&serialOut:     NewSerial(scanner, filter.NewNoop(), sink.NewFiles(&serialOut, sink.WithFilesFormat(sink.CountFormat), sink.WithFilesGetValues(sink.CountGetValues)), logger, serialOptions...),
+				&concurrentOut: NewConcurrent(scanner, filter.NewNoop(), sink.NewFiles(&concurrentOut, sink.WithFilesFormat(sink.CountFormat), sink.WithFilesGetValues(sink.CountGetValues)), logger, 4, 0, concurrentOptions...),
+				&orderedOut:    NewConcurrent(scanner, filter.NewNoop(), sink.NewFiles(&orderedOut, sink.WithFilesFormat(sink.CountFormat), sink.WithFilesGetValues(sink.CountGetValues)), logger, 4, 0, append(concurrentOptions, WithOrdered())...),
+			}
+			for _, searcher := range searchers {
+				searcher.Search(context.Background(), "aaa", matcher.NewRegexp(regexp.MustCompile("match")))
+			}
+			expected := "aaa/bbb:2\naaa/ddd:2\n"
+			if firstMatch {
+				expected = "aaa/bbb:1\naaa/ddd:1\n"
+			}
+			lines := strings.SplitAfter(concurrentOut.String(), "\n")
+			slices.Sort(lines)
+			if serialOut.String() != expected || orderedOut.String() != expected || strings.Join(lines, "") != expected {
+				t.Errorf("First match %v over %s: outputs %q, %q, %q", firstMatch, readerName, serialOut.String(), concurrentOut.String(), orderedOut.String())
+			}
+		}
+	}
+}
+
+func TestSearchers_SkippedFileEnd(t *testing.T) {
+	now := time.Now().UTC()
+	binaryContent := "no\x00"
+	otherContent := "no"
+	testEntries := reader.MockEntries{
+		"aaa":     {ModTime: now},
+		"aaa/bbb": {ModTime: now, Content: &binaryContent},
+		"aaa/ccc": {ModTime: now, Content: &otherContent},
+	}
+	logger := log.New(io.Discard, "", 0)
+	for readerName, newReader := range testReaders {
+		scanner := scanner.NewLine(newReader(testEntries), scanner.WithBinaryMode(scanner.BinarySkip))
+		var serialOut, concurrentOut, orderedOut strings.Builder
+		searchers := map[*strings.Builder]base.Searcher{
+			&serialOut:     NewSerial(scanner, filter.NewNoop(), sink.NewFiles(&serialOut, sink.WithFilesSelected(sink.FilesWithoutMatches)), logger),
+			&concurrentOut: NewConcurrent(scanner, filter.NewNoop(), sink.NewFiles(&concurrentOut, sink.WithFilesSelected(sink.FilesWithoutMatches)), logger, 4, 0),
+			&orderedOut:    NewConcurrent(scanner, filter.NewNoop(), sink.NewFiles(&orderedOut, sink.WithFilesSelected(sink.FilesWithoutMatches)), logger, 4, 0, WithOrdered()),
+		}
+		for out, searcher := range searchers {
+			summary := searcher.Search(context.Background(), "aaa", matcher.NewRegexp(regexp.MustCompile("match")))
+			// Skipped binary file is neither a file without matches nor an error
+			if out.String() != "aaa/ccc\n" || summary.Errors != 0 {
+				t.Errorf("Over %s: output %q, summary %v", readerName, out.String(), summary)
+			}
+		}
+	}
+}
+
 func BenchmarkSerialSearcher(b *testing.B) {
 	b.Run("1", func(b *testing.B) {
 		benchmarkSerialSearcher(b, 103, 5, 2, 4, 4)
@@ -52,10 +168,207 @@ func benchmarkSerialSearcher(b *testing.B, seed int64, maxLines, maxDepth, maxDi
 	sink := sink.NewNoop()
 	searcher := NewSerial(scanner, filter, sink, log.Default())
 	ctx := context.Background()
-	re := regexp.MustCompile("and")
+	re := matcher.NewRegexp(regexp.MustCompile("and"))
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		searcher.Search(ctx, rootName, re)
 	}
 }
+
+func TestSearchers_Results(t *testing.T) {
+	now := time.Now().UTC()
+	content := "match\nno\nmatch match"
+	testError := errors.New("test")
+	testEntries := reader.MockEntries{
+		"aaa":     {ModTime: now},
+		"aaa/bbb": {ModTime: now, Content: &content},
+		"aaa/ccc": {ModTime: now, Content: &content},
+		"aaa/ddd": {ModTime: now, Content: &content, Err: testError},
+	}
+	scanner := scanner.NewLine(reader.NewMockFS(testEntries))
+	logger := log.New(io.Discard, "", 0)
+	searchers := map[string]base.Searcher{
+		"serial":             NewSerial(scanner, filter.NewNoop(), sink.NewNoop(), logger),
+		"concurrent":         NewConcurrent(scanner, filter.NewNoop(), sink.NewNoop(), logger, 4, 0),
+		"concurrent ordered": NewConcurrent(scanner, filter.NewNoop(), sink.NewNoop(), logger, 4, 0, WithOrdered()),
+	}
+	re := matcher.NewRegexp(regexp.MustCompile("match"))
+	goroutines := runtime.NumGoroutine()
+	for name, searcher := range searchers {
+		results, summary := searcher.Results(context.Background(), "aaa", re)
+		var paths []string
+		var errs []error
+		for result, err := range results {
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			paths = append(paths, result.Path)
+		}
+		slices.Sort(paths)
+		if !slices.Equal(paths, []string{"aaa/bbb", "aaa/bbb", "aaa/ccc", "aaa/ccc"}) || len(errs) != 1 || !errors.Is(errs[0], testError) {
+			t.Errorf("%s: Results yielded %v, %v", name, paths, errs)
+		}
+		if summary.Matches != 4 || summary.Errors != 1 {
+			t.Errorf("%s: Results summary %v", name, *summary)
+		}
+
+		count := 0
+		for range results {
+			count++
+			if count == 2 {
+				break
+			}
+		}
+		if count != 2 {
+			t.Errorf("%s: Results yielded %v before break", name, count)
+		}
+	}
+	// Goroutines of cancelled searches end
+	for i := 0; i < 100 && runtime.NumGoroutine() > goroutines; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if runtime.NumGoroutine() > goroutines {
+		t.Errorf("Goroutines left after break: %v, want %v", runtime.NumGoroutine(), goroutines)
+	}
+}
+
+func TestSearchers_Follow(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, dir := range []string{"dir", "other"} {
+		if err := os.Mkdir(filepath.Join(tempDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tempDir, dir, "file.txt"), []byte("match"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"dir/broken": filepath.Join(tempDir, "missing"),
+		"dir/loop":   tempDir,
+		"dir/other":  filepath.Join(tempDir, "other"),
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(tempDir, link)); err != nil {
+			t.Skip("Symlinks are not supported", err)
+		}
+	}
+	scanner := scanner.NewLine(reader.NewFileSystem(reader.WithFollow()))
+	logger := log.New(io.Discard, "", 0)
+	searchers := map[string]base.Searcher{
+		"serial":             NewSerial(scanner, filter.NewNoop(), sink.NewNoop(), logger),
+		"concurrent":         NewConcurrent(scanner, filter.NewNoop(), sink.NewNoop(), logger, 4, 0),
+		"concurrent ordered": NewConcurrent(scanner, filter.NewNoop(), sink.NewNoop(), logger, 4, 0, WithOrdered()),
+	}
+	re := matcher.NewRegexp(regexp.MustCompile("match"))
+	for name, searcher := range searchers {
+		results, summary := searcher.Results(context.Background(), filepath.Join(tempDir, "dir"), re)
+		var paths []string
+		var errs []error
+		var warnings []*base.Warning
+		for result, err := range results {
+			var warning *base.Warning
+			if errors.As(err, &warning) {
+				warnings = append(warnings, warning)
+				continue
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			rel, _ := filepath.Rel(tempDir, result.Path)
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		slices.Sort(paths)
+		if !slices.Equal(paths, []string{"dir/file.txt", "dir/other/file.txt"}) {
+			t.Errorf("%s: Results yielded %v", name, paths)
+		}
+		// Only broken link is an error, loop is skipped with a warning
+		if len(errs) != 1 || summary.Errors != 1 || !errors.Is(errs[0], reader.ErrBrokenLink) {
+			t.Errorf("%s: Results yielded errors %v, summary %v", name, errs, *summary)
+		}
+		if len(warnings) != 1 || len(summary.Warnings) != 1 || !errors.Is(warnings[0], reader.ErrLinkLoop) {
+			t.Errorf("%s: Results yielded warnings %v, summary %v", name, warnings, *summary)
+		}
+	}
+}
+
+func TestSearchers_UnreadableDir(t *testing.T) {
+	now := time.Now().UTC()
+	content := "match"
+	otherContent := "no"
+	testError := errors.New("test")
+	testEntries := reader.MockEntries{
+		"aaa":         {ModTime: now},
+		"aaa/bbb":     {ModTime: now, Err: testError},
+		"aaa/bbb/ccc": {ModTime: now, Content: &content},
+		"aaa/ddd":     {ModTime: now, Content: &otherContent},
+	}
+	logger := log.New(io.Discard, "", 0)
+	re := matcher.NewRegexp(regexp.MustCompile("match"))
+	for readerName, newReader := range testReaders {
+		scanner := scanner.NewLine(newReader(testEntries))
+		searchers := map[string]base.Searcher{
+			"serial":             NewSerial(scanner, filter.NewNoop(), sink.NewNoop(), logger),
+			"concurrent":         NewConcurrent(scanner, filter.NewNoop(), sink.NewNoop(), logger, 4, 0),
+			"concurrent ordered": NewConcurrent(scanner, filter.NewNoop(), sink.NewNoop(), logger, 4, 0, WithOrdered()),
+		}
+		for name, searcher := range searchers {
+			// Directory is skipped with a warning that tells the search failed in part, even with no matches
+			summary := searcher.Search(context.Background(), "aaa", re)
+			if summary.Matches != 0 || summary.Errors != 0 || len(summary.Warnings) != 1 || summary.Warnings[0].Path != "aaa/bbb" || !errors.Is(summary.Warnings[0], testError) {
+				t.Errorf("%s over %s: summary %v", name, readerName, summary)
+			}
+		}
+	}
+}
+
+func TestSearchers_Warnings(t *testing.T) {
+	now := time.Now().UTC()
+	content := "match"
+	testError := errors.New("test")
+	testEntries := reader.MockEntries{
+		"aaa":     {ModTime: now},
+		"aaa/bbb": {ModTime: now, Content: &content},
+		"aaa/ccc": {ModTime: now, Content: &content, StatErr: testError},
+		"aaa/ddd": {ModTime: now, Content: &content},
+	}
+	logger := log.New(io.Discard, "", 0)
+	re := matcher.NewRegexp(regexp.MustCompile("match"))
+	for readerName, newReader := range testReaders {
+		scanner := scanner.NewLine(newReader(testEntries))
+		searchers := map[string]base.Searcher{
+			"serial":             NewSerial(scanner, filter.NewNoop(), sink.NewNoop(), logger),
+			"concurrent":         NewConcurrent(scanner, filter.NewNoop(), sink.NewNoop(), logger, 4, 0),
+			"concurrent ordered": NewConcurrent(scanner, filter.NewNoop(), sink.NewNoop(), logger, 4, 0, WithOrdered()),
+		}
+		for name, searcher := range searchers {
+			results, summary := searcher.Results(context.Background(), "aaa", re)
+			var paths []string
+			var warnings []*base.Warning
+			for result, err := range results {
+				var warning *base.Warning
+				if errors.As(err, &warning) {
+					warnings = append(warnings, warning)
+					continue
+				}
+				if err != nil {
+					t.Errorf("%s over %s: Results yielded error %v", name, readerName, err)
+					continue
+				}
+				paths = append(paths, result.Path)
+			}
+			slices.Sort(paths)
+			if !slices.Equal(paths, []string{"aaa/bbb", "aaa/ddd"}) {
+				t.Errorf("%s over %s: Results yielded %v", name, readerName, paths)
+			}
+			if len(warnings) != 1 || warnings[0].Path != "aaa/ccc" || !errors.Is(warnings[0], testError) {
+				t.Errorf("%s over %s: Results yielded warnings %v", name, readerName, warnings)
+			}
+			if summary.Matches != 2 || summary.Errors != 0 || len(summary.Warnings) != 1 || summary.Warnings[0].Path != "aaa/ccc" {
+				t.Errorf("%s over %s: Results summary %v", name, readerName, *summary)
+			}
+		}
+	}
+}
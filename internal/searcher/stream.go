@@ -0,0 +1,83 @@
+package searcher
+
+import (
+	"context"
+	"iter"
+	"log"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+// Sink that also handles errors of dirs and files.
+// Concurrent searcher calls HandleError from several goroutines
+type errorSink interface {
+	HandleError(err error)
+}
+
+// Logs an error, counts it and passes it to the sink if it handles errors
+func reportError(logger *log.Logger, sink base.Sink, summary *summaryCollector, message string, err error) {
+	logger.Println(message, err)
+	summary.addError(err)
+	if errSink, ok := sink.(errorSink); ok {
+		errSink.HandleError(err)
+	}
+}
+
+// Logs a warning, collects it and passes it to the sink as an error if the sink handles errors
+func reportWarning(logger *log.Logger, sink base.Sink, summary *summaryCollector, warning *base.Warning) {
+	logger.Println("Warning", warning)
+	summary.addWarning(warning)
+	if errSink, ok := sink.(errorSink); ok {
+		errSink.HandleError(warning)
+	}
+}
+
+// Item passed from a searcher to an iterator
+type streamItem struct {
+	result base.SearchResult
+	err    error
+}
+
+// Sink that sends results and errors to an iterator until context is done
+type streamSink struct {
+	ctx   context.Context
+	items chan<- streamItem
+}
+
+func (s *streamSink) HandleResult(result base.SearchResult) {
+	select {
+	case s.items <- streamItem{result: result}:
+	case <-s.ctx.Done():
+	}
+}
+
+func (s *streamSink) HandleFileEnd(path string, matches int) {}
+
+func (s *streamSink) HandleError(err error) {
+	select {
+	case s.items <- streamItem{err: err}:
+	case <-s.ctx.Done():
+	}
+}
+
+// Runs a search with a sink that streams results to the returned iterator.
+// Summary is set when the search ends. Breaking the loop cancels the search and waits until it ends
+func streamResults(ctx context.Context, summary *base.SearchSummary, search func(ctx context.Context, sink base.Sink) base.SearchSummary) iter.Seq2[base.SearchResult, error] {
+	return func(yield func(base.SearchResult, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		items := make(chan streamItem)
+		go func() {
+			*summary = search(ctx, &streamSink{ctx, items})
+			close(items)
+		}()
+		for item := range items {
+			if !yield(item.result, item.err) {
+				cancel()
+				for range items {
+				}
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package searcher
+
+import (
+	"sync"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+// Collects search summary.
+// Thread-safe.
+type summaryCollector struct {
+	mu      sync.Mutex
+	summary base.SearchSummary
+}
+
+func (s *summaryCollector) addResult(result base.SearchResult) {
+	if result.IsContext {
+		return
+	}
+	s.mu.Lock()
+	s.summary.Matches++
+	s.mu.Unlock()
+}
+
+func (s *summaryCollector) addError(err error) {
+	s.mu.Lock()
+	s.summary.Errors++
+	if s.summary.Err == nil {
+		s.summary.Err = err
+	}
+	s.mu.Unlock()
+}
+
+func (s *summaryCollector) addWarning(warning *base.Warning) {
+	s.mu.Lock()
+	s.summary.Warnings = append(s.summary.Warnings, warning)
+	s.mu.Unlock()
+}
+
+func (s *summaryCollector) get() base.SearchSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.summary
+}
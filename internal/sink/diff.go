@@ -0,0 +1,88 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+)
+
+// Number of unchanged lines shown around changed ones in a unified diff
+const diffContext = 3
+
+// Writes a unified diff between lines and the same lines where some of them are replaced.
+// Replaced lines are keyed by 0-based index and may contain several lines each or none
+func writeUnifiedDiff(w io.Writer, path string, lines [][]byte, replaced map[int][]byte) {
+	changed := make([]int, 0, len(replaced))
+	for index := range replaced {
+		changed = append(changed, index)
+	}
+	slices.Sort(changed)
+
+	// Relative paths get prefixes that are conventional for patch -p1
+	oldPath, newPath := path, path
+	if !filepath.IsAbs(path) {
+		oldPath = "a/" + filepath.ToSlash(path)
+		newPath = "b/" + filepath.ToSlash(path)
+	}
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", oldPath, newPath)
+	// Number of lines added by replacements before the current hunk
+	shift := 0
+	for i := 0; i < len(changed); {
+		// Hunk includes changed lines that are close enough to share context
+		j := i + 1
+		for j < len(changed) && changed[j]-changed[j-1] <= 2*diffContext {
+			j++
+		}
+		start := max(changed[i]-diffContext, 0)
+		end := min(changed[j-1]+diffContext+1, len(lines))
+
+		newCount := end - start
+		for _, index := range changed[i:j] {
+			newCount += len(splitLines(replaced[index])) - 1
+		}
+		fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", start+1, end-start, start+1+shift, newCount)
+		shift += newCount - (end - start)
+
+		for index := start; index < end; {
+			if _, ok := replaced[index]; !ok {
+				writeDiffLine(w, ' ', lines[index])
+				index++
+				continue
+			}
+			// Removed lines of a block of adjacent changes go before added ones
+			blockEnd := index
+			for _, ok := replaced[blockEnd]; ok && blockEnd < end; _, ok = replaced[blockEnd] {
+				writeDiffLine(w, '-', lines[blockEnd])
+				blockEnd++
+			}
+			for ; index < blockEnd; index++ {
+				for _, line := range splitLines(replaced[index]) {
+					writeDiffLine(w, '+', line)
+				}
+			}
+		}
+		i = j
+	}
+}
+
+func writeDiffLine(w io.Writer, prefix byte, line []byte) {
+	w.Write([]byte{prefix})
+	w.Write(line)
+	if !bytes.HasSuffix(line, []byte("\n")) {
+		io.WriteString(w, "\n\\ No newline at end of file\n")
+	}
+}
+
+// Splits text after each new line. Last line may have no new line
+func splitLines(text []byte) [][]byte {
+	if len(text) == 0 {
+		return nil
+	}
+	lines := bytes.SplitAfter(text, []byte("\n"))
+	if len(lines) > 1 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
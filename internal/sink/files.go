@@ -0,0 +1,73 @@
+package sink
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+type Files struct {
+	writer    io.Writer
+	format    string
+	getValues func(path string, matches int) []any
+	selected  func(matches int) bool
+}
+
+type FilesOption func(*Files)
+
+func WithFilesFormat(format string) FilesOption {
+	return func(f *Files) {
+		f.format = format
+	}
+}
+
+func WithFilesGetValues(getValues func(path string, matches int) []any) FilesOption {
+	return func(f *Files) {
+		f.getValues = getValues
+	}
+}
+
+// Sets which files are written depending on number of their results that have matches.
+// Default is FilesWithMatches
+func WithFilesSelected(selected func(matches int) bool) FilesOption {
+	return func(f *Files) {
+		f.selected = selected
+	}
+}
+
+// Selects files that have at least one result that has matches
+func FilesWithMatches(matches int) bool {
+	return matches > 0
+}
+
+// Selects files that have no results that have matches
+func FilesWithoutMatches(matches int) bool {
+	return matches == 0
+}
+
+// Selects every scanned file
+func AllFiles(matches int) bool {
+	return true
+}
+
+// Sink that writes a formatted string per scanned file instead of results.
+// Not thread-safe.
+func NewFiles(writer io.Writer, options ...FilesOption) base.Sink {
+	sink := Files{writer: writer, format: DefaultFilesFormat, getValues: DefaultGetFilesValues, selected: FilesWithMatches}
+	for _, option := range options {
+		option(&sink)
+	}
+	return &sink
+}
+
+func (f *Files) HandleResult(result base.SearchResult) {
+	// results are counted by searcher
+}
+
+func (f *Files) HandleFileEnd(path string, matches int) {
+	if !f.selected(matches) {
+		return
+	}
+	fmt.Fprintf(f.writer, f.format, f.getValues(path, matches)...)
+}
@@ -0,0 +1,42 @@
+package sink
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+func TestFilesSink_HandleFileEnd(t *testing.T) {
+	var sb strings.Builder
+	sink := NewFiles(&sb)
+
+	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 1, Line: "test test", Matches: []base.Match{{StartIndex: 2, EndIndex: 5}}})
+	sink.HandleFileEnd("a/b/c.txt", 1)
+	sink.HandleFileEnd("a/b/d.txt", 0)
+	if sb.String() != "a/b/c.txt\n" {
+		t.Errorf("Invalid output: %q", sb.String())
+	}
+}
+
+func TestFilesWithoutMatchesSink_HandleFileEnd(t *testing.T) {
+	var sb strings.Builder
+	sink := NewFiles(&sb, WithFilesSelected(FilesWithoutMatches))
+
+	sink.HandleFileEnd("a/b/c.txt", 1)
+	sink.HandleFileEnd("a/b/d.txt", 0)
+	if sb.String() != "a/b/d.txt\n" {
+		t.Errorf("Invalid output: %q", sb.String())
+	}
+}
+
+func TestCountSink_HandleFileEnd(t *testing.T) {
+	var sb strings.Builder
+	sink := NewFiles(&sb, WithFilesFormat(CountFormat), WithFilesGetValues(CountGetValues), WithFilesSelected(AllFiles))
+
+	sink.HandleFileEnd("a/b/c.txt", 3)
+	sink.HandleFileEnd("a/b/d.txt", 0)
+	if sb.String() != "a/b/c.txt:3\na/b/d.txt:0\n" {
+		t.Errorf("Invalid output: %q", sb.String())
+	}
+}
@@ -1,18 +1,120 @@
 package sink
 
 import (
+	"fmt"
+	"strings"
 	"unicode/utf8"
 
 	"github.com/fatih/color"
 	"github.com/pi-kei/mgrep/internal/base"
 )
 
-var DefaultFormat = "%s[%d,%d]:%s%s%s\n"
+var DefaultFormat = "%s[%d,%d]:%s\n"
 var highlight = color.New(color.Bold, color.FgHiYellow).SprintFunc()
 
 func DefaultGetValues(result base.SearchResult) []any {
-	startPart := result.Line[0:result.StartIndex]
-	resultPart := highlight(result.Line[result.StartIndex:result.EndIndex])
-	endPart := result.Line[result.EndIndex:]
-	return []any{result.Path, result.LineNumber, utf8.RuneCountInString(startPart) + 1, startPart, resultPart, endPart}
+	var sb strings.Builder
+	lastIndex := 0
+	for _, match := range result.Matches {
+		sb.WriteString(result.Line[lastIndex:match.StartIndex])
+		// Each line of a match is highlighted separately so new lines are not highlighted
+		for i, part := range strings.Split(result.Line[match.StartIndex:match.EndIndex], "\n") {
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			sb.WriteString(highlight(part))
+		}
+		lastIndex = match.EndIndex
+	}
+	sb.WriteString(result.Line[lastIndex:])
+	if result.Truncated {
+		sb.WriteString(TruncatedMark)
+	}
+	return []any{result.Path, result.LineNumber, column(result), continueLines(result, sb.String())}
+}
+
+// Appended to lines that are too long to be shown in full
+var TruncatedMark = " [truncated]"
+
+var DefaultContextFormat = "%s[%d]-%s\n"
+
+func DefaultGetContextValues(result base.SearchResult) []any {
+	if result.Truncated {
+		return []any{result.Path, result.LineNumber, result.Line + TruncatedMark}
+	}
+	return []any{result.Path, result.LineNumber, result.Line}
+}
+
+var DefaultBinaryFormat = "Binary file %s matches\n"
+
+func DefaultGetBinaryValues(result base.SearchResult) []any {
+	return []any{result.Path}
+}
+
+// Separates groups of lines that are not adjacent when context is shown
+var DefaultGroupSeparator = "--\n"
+
+// Prints every match on a separate line
+var OnlyMatchingFormat = "%s"
+
+func OnlyMatchingGetValues(result base.SearchResult) []any {
+	var sb strings.Builder
+	for _, match := range result.Matches {
+		lineNumber := result.LineNumber + strings.Count(result.Line[:match.StartIndex], "\n")
+		for i, part := range strings.Split(result.Line[match.StartIndex:match.EndIndex], "\n") {
+			if i == 0 {
+				fmt.Fprintf(&sb, "%s[%d,%d]:%s\n", result.Path, lineNumber, result.ColumnOffset+runeColumn(result.Line, match.StartIndex), highlight(part))
+			} else {
+				fmt.Fprintf(&sb, "%s[%d]:%s\n", result.Path, lineNumber+i, highlight(part))
+			}
+		}
+	}
+	return []any{sb.String()}
+}
+
+// Returns 1-based rune column of the first match
+func column(result base.SearchResult) int {
+	if len(result.Matches) == 0 {
+		return result.ColumnOffset + 1
+	}
+	return result.ColumnOffset + runeColumn(result.Line, result.Matches[0].StartIndex)
+}
+
+// Returns 1-based rune column of a byte at the index in its own line
+func runeColumn(text string, index int) int {
+	return utf8.RuneCountInString(text[strings.LastIndexByte(text[:index], '\n')+1:index]) + 1
+}
+
+// Prefixes each line after the first one of a result that spans several lines with path and line number
+func continueLines(result base.SearchResult, text string) string {
+	if result.EndLineNumber == 0 {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		lines[i] = fmt.Sprintf("%s[%d]:%s", result.Path, result.LineNumber+i, lines[i])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Returns number of the last line of a result
+func lastLineNumber(result base.SearchResult) int {
+	if result.EndLineNumber > 0 {
+		return result.EndLineNumber
+	}
+	return result.LineNumber
+}
+
+// Prints a path of a file
+var DefaultFilesFormat = "%s\n"
+
+func DefaultGetFilesValues(path string, matches int) []any {
+	return []any{path}
+}
+
+// Prints a path of a file and number of its results that have matches
+var CountFormat = "%s:%d\n"
+
+func CountGetValues(path string, matches int) []any {
+	return []any{path, matches}
 }
@@ -0,0 +1,162 @@
+package sink
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"unicode/utf8"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+// Arbitrary data. Text is set when data is valid UTF-8, otherwise Bytes is set with base64 encoded data
+type jsonData struct {
+	Text  *string `json:"text,omitempty"`
+	Bytes *string `json:"bytes,omitempty"`
+}
+
+type jsonSubmatch struct {
+	Match       jsonData `json:"match"`
+	Start       int      `json:"start"`        // start byte offset in line 0-based
+	End         int      `json:"end"`          // end byte offset (exclusive) in line 0-based
+	StartColumn int      `json:"start_column"` // start rune column 1-based in its own full line
+	EndColumn   int      `json:"end_column"`   // end rune column (exclusive) 1-based in its own full line
+	Pattern     int      `json:"pattern"`      // index of a pattern that matched 0-based
+}
+
+type jsonStats struct {
+	MatchedLines int `json:"matched_lines"`
+	Matches      int `json:"matches"`
+}
+
+type jsonSummaryStats struct {
+	jsonStats
+	Files            int `json:"files"`              // number of scanned files
+	FilesWithMatches int `json:"files_with_matches"` // number of files that have matches
+}
+
+type jsonBegin struct {
+	Path jsonData `json:"path"`
+}
+
+type jsonLine struct {
+	Path          jsonData       `json:"path"`
+	LineNumber    int            `json:"line_number"`
+	EndLineNumber int            `json:"end_line_number,omitempty"` // set when line has several lines
+	Line          jsonData       `json:"line"`
+	Truncated     bool           `json:"truncated,omitempty"` // set when line is a part of a longer line
+	Offset        int            `json:"offset,omitempty"`    // byte offset of line in the full line when truncated
+	Submatches    []jsonSubmatch `json:"submatches"`
+}
+
+type jsonBinary struct {
+	Path       jsonData `json:"path"`
+	LineNumber int      `json:"line_number"` // number of the first line that has a match
+}
+
+type jsonEnd struct {
+	Path  jsonData  `json:"path"`
+	Stats jsonStats `json:"stats"`
+}
+
+type jsonSummary struct {
+	Stats jsonSummaryStats `json:"stats"`
+}
+
+type jsonMessage struct {
+	Type string `json:"type"` // one of begin, match, context, binary, end or summary
+	Data any    `json:"data"`
+}
+
+type JSON struct {
+	encoder   *json.Encoder
+	lastPath  string
+	lastStats jsonStats
+	summary   jsonSummaryStats
+}
+
+// Sink that writes JSON Lines to a specified writer.
+// Results of each file are wrapped with begin and end messages.
+// A new begin message is written every time path of a result changes,
+// so results of a file must not be interleaved with results of other files.
+// End message is written when the file ends or path of a result changes.
+// Summary message is written on Close.
+// Not thread-safe.
+func NewJSON(writer io.Writer) base.Sink {
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	return &JSON{encoder: encoder}
+}
+
+func (j *JSON) HandleResult(result base.SearchResult) {
+	if result.Path != j.lastPath {
+		j.end()
+		j.lastPath = result.Path
+		j.encoder.Encode(jsonMessage{"begin", jsonBegin{newJSONData(result.Path)}})
+	}
+	if result.IsBinary {
+		if j.lastStats.MatchedLines == 0 {
+			j.summary.FilesWithMatches++
+		}
+		j.lastStats.MatchedLines++
+		j.encoder.Encode(jsonMessage{"binary", jsonBinary{newJSONData(result.Path), result.LineNumber}})
+		return
+	}
+	messageType := "match"
+	if result.IsContext {
+		messageType = "context"
+	} else {
+		if j.lastStats.MatchedLines == 0 {
+			j.summary.FilesWithMatches++
+		}
+		j.lastStats.MatchedLines++
+		j.lastStats.Matches += len(result.Matches)
+	}
+	submatches := make([]jsonSubmatch, len(result.Matches))
+	for i, match := range result.Matches {
+		submatches[i] = jsonSubmatch{
+			Match:       newJSONData(result.Line[match.StartIndex:match.EndIndex]),
+			Start:       match.StartIndex,
+			End:         match.EndIndex,
+			StartColumn: result.ColumnOffset + runeColumn(result.Line, match.StartIndex),
+			EndColumn:   result.ColumnOffset + runeColumn(result.Line, match.EndIndex),
+			Pattern:     match.Pattern,
+		}
+	}
+	j.encoder.Encode(jsonMessage{messageType, jsonLine{newJSONData(result.Path), result.LineNumber, result.EndLineNumber, newJSONData(result.Line), result.Truncated, result.LineOffset, submatches}})
+}
+
+// Counts a scanned file and writes its end message if it has results
+func (j *JSON) HandleFileEnd(path string, matches int) {
+	j.summary.Files++
+	if path != j.lastPath {
+		return
+	}
+	j.end()
+	j.lastPath = ""
+}
+
+// Writes end message of the last file and summary message
+func (j *JSON) Close() error {
+	j.end()
+	j.lastPath = ""
+	return j.encoder.Encode(jsonMessage{"summary", jsonSummary{j.summary}})
+}
+
+func (j *JSON) end() {
+	if len(j.lastPath) == 0 {
+		return
+	}
+	j.encoder.Encode(jsonMessage{"end", jsonEnd{newJSONData(j.lastPath), j.lastStats}})
+	j.summary.MatchedLines += j.lastStats.MatchedLines
+	j.summary.Matches += j.lastStats.Matches
+	j.lastStats = jsonStats{}
+}
+
+func newJSONData(data string) jsonData {
+	if utf8.ValidString(data) {
+		return jsonData{Text: &data}
+	}
+	encoded := base64.StdEncoding.EncodeToString([]byte(data))
+	return jsonData{Bytes: &encoded}
+}
@@ -0,0 +1,42 @@
+package sink
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+func TestJSONSink_HandleResult(t *testing.T) {
+	var sb strings.Builder
+	sink := NewJSON(&sb)
+
+	sink.HandleResult(base.SearchResult{Path: "a/b:c.txt", LineNumber: 1, Line: "before", IsContext: true})
+	sink.HandleResult(base.SearchResult{Path: "a/b:c.txt", LineNumber: 2, Line: "тест test", Matches: []base.Match{{StartIndex: 2, EndIndex: 6}, {StartIndex: 9, EndIndex: 11, Pattern: 1}}})
+	sink.HandleFileEnd("a/b:c.txt", 1)
+	sink.HandleFileEnd("a/b/e.txt", 0)
+	sink.HandleResult(base.SearchResult{Path: "a/b/d.txt", LineNumber: 3, Line: "\xfftest", Matches: []base.Match{{StartIndex: 1, EndIndex: 5}}})
+	err := sink.(*JSON).Close()
+	if err != nil {
+		t.Errorf("Close returned error %v", err)
+	}
+	expected := []string{
+		`{"type":"begin","data":{"path":{"text":"a/b:c.txt"}}}`,
+		`{"type":"context","data":{"path":{"text":"a/b:c.txt"},"line_number":1,"line":{"text":"before"},"submatches":[]}}`,
+		`{"type":"match","data":{"path":{"text":"a/b:c.txt"},"line_number":2,"line":{"text":"тест test"},"submatches":[{"match":{"text":"ес"},"start":2,"end":6,"start_column":2,"end_column":4,"pattern":0},{"match":{"text":"te"},"start":9,"end":11,"start_column":6,"end_column":8,"pattern":1}]}}`,
+		`{"type":"end","data":{"path":{"text":"a/b:c.txt"},"stats":{"matched_lines":1,"matches":2}}}`,
+		`{"type":"begin","data":{"path":{"text":"a/b/d.txt"}}}`,
+		`{"type":"match","data":{"path":{"text":"a/b/d.txt"},"line_number":3,"line":{"bytes":"/3Rlc3Q="},"submatches":[{"match":{"text":"test"},"start":1,"end":5,"start_column":2,"end_column":6,"pattern":0}]}}`,
+		`{"type":"end","data":{"path":{"text":"a/b/d.txt"},"stats":{"matched_lines":1,"matches":1}}}`,
+		`{"type":"summary","data":{"stats":{"matched_lines":2,"matches":3,"files":2,"files_with_matches":2}}}`,
+	}
+	out := strings.Split(strings.TrimSuffix(sb.String(), "\n"), "\n")
+	if len(out) != len(expected) {
+		t.Fatalf("Invalid output: %s", sb.String())
+	}
+	for i := range expected {
+		if out[i] != expected[i] {
+			t.Errorf("Invalid line %v: %s expected %s", i, out[i], expected[i])
+		}
+	}
+}
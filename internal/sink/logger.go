@@ -2,14 +2,23 @@ package sink
 
 import (
 	"log"
+	"sync"
 
 	"github.com/pi-kei/mgrep/internal/base"
 )
 
 type Logger struct {
-	logger    *log.Logger
-	format    string
-	getValues func(result base.SearchResult) []any
+	logger           *log.Logger
+	format           string
+	getValues        func(result base.SearchResult) []any
+	contextFormat    string
+	getContextValues func(result base.SearchResult) []any
+	binaryFormat     string
+	getBinaryValues  func(result base.SearchResult) []any
+	groupSeparator   string
+	mu               sync.Mutex
+	lastPath         string
+	lastLineNumber   int
 }
 
 type LoggerOption func(*Logger)
@@ -26,10 +35,42 @@ func WithLoggerGetValues(getValues func(result base.SearchResult) []any) LoggerO
 	}
 }
 
+func WithLoggerContextFormat(format string) LoggerOption {
+	return func(l *Logger) {
+		l.contextFormat = format
+	}
+}
+
+func WithLoggerGetContextValues(getValues func(result base.SearchResult) []any) LoggerOption {
+	return func(l *Logger) {
+		l.getContextValues = getValues
+	}
+}
+
+func WithLoggerBinaryFormat(format string) LoggerOption {
+	return func(l *Logger) {
+		l.binaryFormat = format
+	}
+}
+
+func WithLoggerGetBinaryValues(getValues func(result base.SearchResult) []any) LoggerOption {
+	return func(l *Logger) {
+		l.getBinaryValues = getValues
+	}
+}
+
+// Sets separator that is logged between groups of lines that are not adjacent.
+// Empty separator means no separation
+func WithLoggerGroupSeparator(separator string) LoggerOption {
+	return func(l *Logger) {
+		l.groupSeparator = separator
+	}
+}
+
 // Sink that writes formatted strings using specified logger.
 // Thread-safe.
 func NewLogger(logger *log.Logger, options ...LoggerOption) base.Sink {
-	sink := Logger{logger, DefaultFormat, DefaultGetValues}
+	sink := Logger{logger: logger, format: DefaultFormat, getValues: DefaultGetValues, contextFormat: DefaultContextFormat, getContextValues: DefaultGetContextValues, binaryFormat: DefaultBinaryFormat, getBinaryValues: DefaultGetBinaryValues}
 	for _, option := range options {
 		option(&sink)
 	}
@@ -37,5 +78,25 @@ func NewLogger(logger *log.Logger, options ...LoggerOption) base.Sink {
 }
 
 func (l *Logger) HandleResult(result base.SearchResult) {
+	if len(l.groupSeparator) > 0 {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if len(l.lastPath) > 0 && (result.Path != l.lastPath || result.LineNumber != l.lastLineNumber+1) {
+			l.logger.Print(l.groupSeparator)
+		}
+		l.lastPath = result.Path
+		l.lastLineNumber = lastLineNumber(result)
+	}
+	if result.IsBinary {
+		l.logger.Printf(l.binaryFormat, l.getBinaryValues(result)...)
+		return
+	}
+	if result.IsContext {
+		l.logger.Printf(l.contextFormat, l.getContextValues(result)...)
+		return
+	}
 	l.logger.Printf(l.format, l.getValues(result)...)
 }
+
+func (l *Logger) HandleFileEnd(path string, matches int) {
+}
@@ -15,3 +15,7 @@ func NewNoop() base.Sink {
 func (n *Noop) HandleResult(result base.SearchResult) {
 	// noop
 }
+
+func (n *Noop) HandleFileEnd(path string, matches int) {
+	// noop
+}
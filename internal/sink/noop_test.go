@@ -9,5 +9,5 @@ import (
 func TestNoopSink_HandleResult(t *testing.T) {
 	sink := NewNoop()
 
-	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 1, StartIndex: 2, EndIndex: 5, Line: "test test"})
+	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 1, Line: "test test", Matches: []base.Match{{StartIndex: 2, EndIndex: 5}}})
 }
@@ -0,0 +1,29 @@
+package sink
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+type Quiet struct {
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+// Sink that writes nothing and cancels a search on the first result that has a match.
+// Thread-safe.
+func NewQuiet(cancel context.CancelFunc) base.Sink {
+	return &Quiet{cancel: cancel}
+}
+
+func (q *Quiet) HandleResult(result base.SearchResult) {
+	if result.IsContext {
+		return
+	}
+	q.once.Do(q.cancel)
+}
+
+func (q *Quiet) HandleFileEnd(path string, matches int) {
+}
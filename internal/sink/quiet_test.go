@@ -0,0 +1,31 @@
+package sink
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+func TestQuietSink_HandleResult(t *testing.T) {
+	calledTimes := 0
+	sink := NewQuiet(func() {
+		calledTimes++
+	})
+
+	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 1, Line: "before", IsContext: true})
+	if calledTimes != 0 {
+		t.Errorf("Cancel called %v times", calledTimes)
+	}
+	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 2, Line: "test test", Matches: []base.Match{{StartIndex: 2, EndIndex: 5}}})
+	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 3, Line: "test", Matches: []base.Match{{StartIndex: 0, EndIndex: 4}}})
+	if calledTimes != 1 {
+		t.Errorf("Cancel called %v times", calledTimes)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	NewQuiet(cancel).HandleResult(base.SearchResult{Path: "a/b/c.bin", LineNumber: 1, IsBinary: true})
+	if ctx.Err() == nil {
+		t.Error("Context is not canceled")
+	}
+}
@@ -0,0 +1,186 @@
+package sink
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/pi-kei/mgrep/internal/base"
+)
+
+var (
+	ErrFileChanged   = errors.New("file changed since it was scanned")
+	ErrLineTruncated = errors.New("line is too long to replace")
+)
+
+type Replace struct {
+	reader   base.Reader
+	replacer base.Replacer
+	template string
+	writer   io.Writer
+	logger   *log.Logger
+	inPlace  bool                           // whether files are rewritten instead of writing a diff
+	pending  map[string][]base.SearchResult // results of files that are not ended yet
+	err      error                          // first occured error
+}
+
+type ReplaceOption func(*Replace)
+
+// Makes sink rewrite files instead of writing a diff.
+// Every file is written to a temporary file first that then replaces the original one
+func WithReplaceInPlace() ReplaceOption {
+	return func(r *Replace) {
+		r.inPlace = true
+	}
+}
+
+// Sets logger of errors. Default is log.Default()
+func WithReplaceLogger(logger *log.Logger) ReplaceOption {
+	return func(r *Replace) {
+		r.logger = logger
+	}
+}
+
+// Sink that replaces matches with a template expanded by a replacer.
+// Writes a unified diff of every changed file to a specified writer.
+// Files are read again with a specified reader when they end.
+// Close returns the first error that occured.
+// Not thread-safe.
+func NewReplace(reader base.Reader, replacer base.Replacer, template string, writer io.Writer, options ...ReplaceOption) base.Sink {
+	sink := Replace{reader: reader, replacer: replacer, template: template, writer: writer, logger: log.Default(), pending: make(map[string][]base.SearchResult)}
+	for _, option := range options {
+		option(&sink)
+	}
+	return &sink
+}
+
+func (r *Replace) HandleResult(result base.SearchResult) {
+	if result.IsContext || result.IsBinary || len(result.Matches) == 0 {
+		return
+	}
+	r.pending[result.Path] = append(r.pending[result.Path], result)
+}
+
+func (r *Replace) HandleFileEnd(path string, matches int) {
+	results, ok := r.pending[path]
+	if !ok {
+		return
+	}
+	delete(r.pending, path)
+	err := r.replace(path, results)
+	if err != nil {
+		r.logger.Println("Error replacing", path, err)
+		if r.err == nil {
+			r.err = err
+		}
+	}
+}
+
+func (r *Replace) Close() error {
+	return r.err
+}
+
+func (r *Replace) replace(path string, results []base.SearchResult) error {
+	file, err := r.reader.OpenFile(base.DirEntry{Path: path})
+	if err != nil {
+		return err
+	}
+	content, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	lines := splitLines(content)
+	replaced := make(map[int][]byte, len(results))
+	for _, result := range results {
+		if result.Truncated {
+			return ErrLineTruncated
+		}
+		first := result.LineNumber - 1
+		last := first
+		if result.EndLineNumber > 0 {
+			last = result.EndLineNumber - 1
+		}
+		if last >= len(lines) {
+			return ErrFileChanged
+		}
+		original := bytes.Join(lines[first:last+1], nil)
+		text, ending := splitLineEnding(original)
+		if string(text) != result.Line {
+			return ErrFileChanged
+		}
+		line := r.replacer.Replace(nil, r.template, text, result.Matches)
+		line = append(line, ending...)
+		if !bytes.Equal(line, original) {
+			// Result that spans several lines is replaced as a whole by its first line
+			replaced[first] = line
+			for index := first + 1; index <= last; index++ {
+				replaced[index] = nil
+			}
+		}
+	}
+	if len(replaced) == 0 {
+		return nil
+	}
+
+	if !r.inPlace {
+		writeUnifiedDiff(r.writer, path, lines, replaced)
+		return nil
+	}
+	var newContent bytes.Buffer
+	for i, line := range lines {
+		if newLine, ok := replaced[i]; ok {
+			line = newLine
+		}
+		newContent.Write(line)
+	}
+	return writeFileAtomic(path, newContent.Bytes())
+}
+
+// Splits a line into text and line ending the same way line scanner does
+func splitLineEnding(line []byte) ([]byte, []byte) {
+	text := bytes.TrimSuffix(line, []byte("\n"))
+	text = bytes.TrimSuffix(text, []byte("\r"))
+	return text, line[len(text):]
+}
+
+// Writes content to a temporary file in the same directory and renames it to the path.
+// Keeps permissions of the original file. Symbolic link is kept and its target is written
+func writeFileAtomic(path string, content []byte) error {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tempPath := temp.Name()
+	_, err = temp.Write(content)
+	if err == nil {
+		err = temp.Chmod(info.Mode().Perm())
+	}
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
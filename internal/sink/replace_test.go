@@ -0,0 +1,127 @@
+package sink
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pi-kei/mgrep/internal/base"
+	"github.com/pi-kei/mgrep/internal/matcher"
+	"github.com/pi-kei/mgrep/internal/reader"
+)
+
+func TestReplaceSink_Diff(t *testing.T) {
+	content := "one foo\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven foo\ntwelve foo"
+	testEntries := reader.MockEntries{
+		"a":       {ModTime: time.Now().UTC()},
+		"a/b.txt": {ModTime: time.Now().UTC(), Content: &content},
+	}
+	re := matcher.NewRegexp(regexp.MustCompile(`f(o+)`))
+	var sb strings.Builder
+	sink := NewReplace(reader.NewMockReader(testEntries), re, "b$1\nbar", &sb)
+
+	lines := strings.Split(content, "\n")
+	for _, lineNumber := range []int{1, 11, 12} {
+		line := lines[lineNumber-1]
+		sink.HandleResult(base.SearchResult{Path: "a/b.txt", LineNumber: lineNumber, Line: line, Matches: re.FindAll([]byte(line), -1)})
+	}
+	sink.HandleFileEnd("a/b.txt", 3)
+	if err := sink.(*Replace).Close(); err != nil {
+		t.Errorf("Close returned error %v", err)
+	}
+	expected := "--- a/a/b.txt\n+++ b/a/b.txt\n" +
+		"@@ -1,4 +1,5 @@\n-one foo\n+one boo\n+bar\n two\n three\n four\n" +
+		"@@ -8,5 +9,7 @@\n eight\n nine\n ten\n-eleven foo\n-twelve foo\n\\ No newline at end of file\n+eleven boo\n+bar\n+twelve boo\n+bar\n\\ No newline at end of file\n"
+	if sb.String() != expected {
+		t.Errorf("Invalid output:\n%s", sb.String())
+	}
+}
+
+func TestReplaceSink_Diff_MultiLine(t *testing.T) {
+	content := "a\nfoo\nbar\nb"
+	testEntries := reader.MockEntries{
+		"a":       {ModTime: time.Now().UTC()},
+		"a/b.txt": {ModTime: time.Now().UTC(), Content: &content},
+	}
+	var sb strings.Builder
+	sink := NewReplace(reader.NewMockReader(testEntries), matcher.NewLiteral(), "baz", &sb)
+
+	sink.HandleResult(base.SearchResult{Path: "a/b.txt", LineNumber: 2, EndLineNumber: 3, Line: "foo\nbar", Matches: []base.Match{{StartIndex: 0, EndIndex: 7}}})
+	sink.HandleFileEnd("a/b.txt", 1)
+	expected := "--- a/a/b.txt\n+++ b/a/b.txt\n@@ -1,4 +1,3 @@\n a\n-foo\n-bar\n+baz\n b\n\\ No newline at end of file\n"
+	if sb.String() != expected {
+		t.Errorf("Invalid output:\n%s", sb.String())
+	}
+}
+
+func TestReplaceSink_InPlace(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "a.txt")
+	err := os.WriteFile(path, []byte("foo bar\r\nbaz foo\n"), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := matcher.NewRegexp(regexp.MustCompile(`foo`))
+	var sb strings.Builder
+	sink := NewReplace(reader.NewFileSystem(), re, "qux", &sb, WithReplaceInPlace())
+
+	sink.HandleResult(base.SearchResult{Path: path, LineNumber: 1, Line: "foo bar", Matches: []base.Match{{StartIndex: 0, EndIndex: 3}}})
+	sink.HandleResult(base.SearchResult{Path: path, LineNumber: 2, Line: "baz foo", Matches: []base.Match{{StartIndex: 4, EndIndex: 7}}})
+	sink.HandleFileEnd(path, 2)
+	if err := sink.(*Replace).Close(); err != nil {
+		t.Errorf("Close returned error %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "qux bar\r\nbaz qux\n" {
+		t.Errorf("Invalid content %q", content)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Invalid permissions %v", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(tempDir)
+	if len(entries) != 1 || sb.Len() != 0 {
+		t.Errorf("Unexpected files %v or output %q", entries, sb.String())
+	}
+
+	// File changed since it was scanned
+	sink = NewReplace(reader.NewFileSystem(), re, "qux", &sb, WithReplaceInPlace(), WithReplaceLogger(log.New(io.Discard, "", 0)))
+	sink.HandleResult(base.SearchResult{Path: path, LineNumber: 1, Line: "foo bar", Matches: []base.Match{{StartIndex: 0, EndIndex: 3}}})
+	sink.HandleFileEnd(path, 1)
+	if err := sink.(*Replace).Close(); err != ErrFileChanged {
+		t.Errorf("Close returned error %v", err)
+	}
+
+	// Link is kept and its target is written
+	linkPath := filepath.Join(tempDir, "link.txt")
+	if err := os.Symlink(path, linkPath); err != nil {
+		t.Skip("Symlinks are not supported", err)
+	}
+	sink = NewReplace(reader.NewFileSystem(), matcher.NewRegexp(regexp.MustCompile(`qux`)), "foo", &sb, WithReplaceInPlace())
+	sink.HandleResult(base.SearchResult{Path: linkPath, LineNumber: 1, Line: "qux bar", Matches: []base.Match{{StartIndex: 0, EndIndex: 3}}})
+	sink.HandleFileEnd(linkPath, 1)
+	if err := sink.(*Replace).Close(); err != nil {
+		t.Errorf("Close returned error %v", err)
+	}
+	if info, err := os.Lstat(linkPath); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Link is replaced %v, %v", info, err)
+	}
+	content, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "foo bar\r\nbaz qux\n" {
+		t.Errorf("Invalid content of the target %q", content)
+	}
+}
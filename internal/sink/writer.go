@@ -8,9 +8,16 @@ import (
 )
 
 type Writer struct {
-	writer    io.Writer
-	format    string
-	getValues func(result base.SearchResult) []any
+	writer           io.Writer
+	format           string
+	getValues        func(result base.SearchResult) []any
+	contextFormat    string
+	getContextValues func(result base.SearchResult) []any
+	binaryFormat     string
+	getBinaryValues  func(result base.SearchResult) []any
+	groupSeparator   string
+	lastPath         string
+	lastLineNumber   int
 }
 
 type WriterOption func(*Writer)
@@ -27,10 +34,42 @@ func WithWriterGetValues(getValues func(result base.SearchResult) []any) WriterO
 	}
 }
 
+func WithWriterContextFormat(format string) WriterOption {
+	return func(w *Writer) {
+		w.contextFormat = format
+	}
+}
+
+func WithWriterGetContextValues(getValues func(result base.SearchResult) []any) WriterOption {
+	return func(w *Writer) {
+		w.getContextValues = getValues
+	}
+}
+
+func WithWriterBinaryFormat(format string) WriterOption {
+	return func(w *Writer) {
+		w.binaryFormat = format
+	}
+}
+
+func WithWriterGetBinaryValues(getValues func(result base.SearchResult) []any) WriterOption {
+	return func(w *Writer) {
+		w.getBinaryValues = getValues
+	}
+}
+
+// Sets separator that is written between groups of lines that are not adjacent.
+// Empty separator means no separation
+func WithWriterGroupSeparator(separator string) WriterOption {
+	return func(w *Writer) {
+		w.groupSeparator = separator
+	}
+}
+
 // Sink that writes formatted strings to a specified writer.
 // Not thread-safe.
 func NewWriter(writer io.Writer, options ...WriterOption) base.Sink {
-	sink := Writer{writer, DefaultFormat, DefaultGetValues}
+	sink := Writer{writer: writer, format: DefaultFormat, getValues: DefaultGetValues, contextFormat: DefaultContextFormat, getContextValues: DefaultGetContextValues, binaryFormat: DefaultBinaryFormat, getBinaryValues: DefaultGetBinaryValues}
 	for _, option := range options {
 		option(&sink)
 	}
@@ -38,5 +77,21 @@ func NewWriter(writer io.Writer, options ...WriterOption) base.Sink {
 }
 
 func (w *Writer) HandleResult(result base.SearchResult) {
+	if len(w.groupSeparator) > 0 && len(w.lastPath) > 0 && (result.Path != w.lastPath || result.LineNumber != w.lastLineNumber+1) {
+		io.WriteString(w.writer, w.groupSeparator)
+	}
+	w.lastPath = result.Path
+	w.lastLineNumber = lastLineNumber(result)
+	if result.IsBinary {
+		fmt.Fprintf(w.writer, w.binaryFormat, w.getBinaryValues(result)...)
+		return
+	}
+	if result.IsContext {
+		fmt.Fprintf(w.writer, w.contextFormat, w.getContextValues(result)...)
+		return
+	}
 	fmt.Fprintf(w.writer, w.format, w.getValues(result)...)
 }
+
+func (w *Writer) HandleFileEnd(path string, matches int) {
+}
@@ -11,7 +11,7 @@ func TestWriterSink_HandleResult(t *testing.T) {
 	var sb strings.Builder
 	sink := NewWriter(&sb)
 
-	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 1, StartIndex: 2, EndIndex: 5, Line: "test test"})
+	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 1, Line: "test test", Matches: []base.Match{{StartIndex: 2, EndIndex: 5}}})
 	out := sb.String()
 	if out != "a/b/c.txt[1,3]:test test\n" {
 		t.Errorf("Invalid output: %s", out)
@@ -23,10 +23,10 @@ func TestCustomWriterSink_HandleResult(t *testing.T) {
 	calledTimes := 0
 	sink := NewWriter(&sb, WithWriterFormat("%s %v %v %v %s"), WithWriterGetValues(func(result base.SearchResult) []any {
 		calledTimes++
-		return []any{result.Path, result.LineNumber, result.StartIndex, result.EndIndex, result.Line}
+		return []any{result.Path, result.LineNumber, result.Matches[0].StartIndex, result.Matches[0].EndIndex, result.Line}
 	}))
 
-	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 1, StartIndex: 2, EndIndex: 5, Line: "test test"})
+	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 1, Line: "test test", Matches: []base.Match{{StartIndex: 2, EndIndex: 5}}})
 	out := sb.String()
 	if out != "a/b/c.txt 1 2 5 test test" {
 		t.Errorf("Invalid output: %s", out)
@@ -35,3 +35,86 @@ func TestCustomWriterSink_HandleResult(t *testing.T) {
 		t.Errorf("Called times %v", calledTimes)
 	}
 }
+
+func TestWriterSink_HandleResult_MultipleMatches(t *testing.T) {
+	var sb strings.Builder
+	sink := NewWriter(&sb)
+
+	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 3, Line: "тест test", Matches: []base.Match{{StartIndex: 2, EndIndex: 6}, {StartIndex: 9, EndIndex: 11}}})
+	out := sb.String()
+	if out != "a/b/c.txt[3,2]:тест test\n" {
+		t.Errorf("Invalid output: %s", out)
+	}
+}
+
+func TestOnlyMatchingWriterSink_HandleResult(t *testing.T) {
+	var sb strings.Builder
+	sink := NewWriter(&sb, WithWriterFormat(OnlyMatchingFormat), WithWriterGetValues(OnlyMatchingGetValues))
+
+	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 3, Line: "тест test", Matches: []base.Match{{StartIndex: 2, EndIndex: 6}, {StartIndex: 9, EndIndex: 11}}})
+	out := sb.String()
+	if out != "a/b/c.txt[3,2]:ес\na/b/c.txt[3,6]:te\n" {
+		t.Errorf("Invalid output: %s", out)
+	}
+}
+
+func TestWriterSink_HandleResult_Context(t *testing.T) {
+	var sb strings.Builder
+	sink := NewWriter(&sb, WithWriterGroupSeparator(DefaultGroupSeparator))
+
+	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 1, Line: "before", IsContext: true})
+	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 2, Line: "test test", Matches: []base.Match{{StartIndex: 2, EndIndex: 5}}})
+	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 5, Line: "test", Matches: []base.Match{{StartIndex: 0, EndIndex: 4}}})
+	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 6, Line: "after", IsContext: true})
+	sink.HandleResult(base.SearchResult{Path: "a/b/d.txt", LineNumber: 7, Line: "test", Matches: []base.Match{{StartIndex: 0, EndIndex: 4}}})
+	out := sb.String()
+	expected := "a/b/c.txt[1]-before\na/b/c.txt[2,3]:test test\n--\na/b/c.txt[5,1]:test\na/b/c.txt[6]-after\n--\na/b/d.txt[7,1]:test\n"
+	if out != expected {
+		t.Errorf("Invalid output: %s", out)
+	}
+}
+
+func TestWriterSink_HandleResult_MultiLine(t *testing.T) {
+	var sb strings.Builder
+	sink := NewWriter(&sb, WithWriterGroupSeparator(DefaultGroupSeparator))
+
+	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 2, EndLineNumber: 3, Line: "one two\nthree", Matches: []base.Match{{StartIndex: 4, EndIndex: 10}}})
+	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 4, Line: "after", IsContext: true})
+	out := sb.String()
+	expected := "a/b/c.txt[2,5]:one two\na/b/c.txt[3]:three\na/b/c.txt[4]-after\n"
+	if out != expected {
+		t.Errorf("Invalid output: %s", out)
+	}
+
+	sb.Reset()
+	sink = NewWriter(&sb, WithWriterFormat(OnlyMatchingFormat), WithWriterGetValues(OnlyMatchingGetValues))
+	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 2, EndLineNumber: 3, Line: "one two\nthree", Matches: []base.Match{{StartIndex: 4, EndIndex: 10}}})
+	out = sb.String()
+	expected = "a/b/c.txt[2,5]:two\na/b/c.txt[3]:th\n"
+	if out != expected {
+		t.Errorf("Invalid output: %s", out)
+	}
+}
+
+func TestWriterSink_HandleResult_Truncated(t *testing.T) {
+	var sb strings.Builder
+	sink := NewWriter(&sb)
+
+	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 1, Line: "a test b", Matches: []base.Match{{StartIndex: 2, EndIndex: 6}}, Truncated: true, LineOffset: 100, ColumnOffset: 90})
+	sink.HandleResult(base.SearchResult{Path: "a/b/c.txt", LineNumber: 2, Line: "context", IsContext: true, Truncated: true})
+	out := sb.String()
+	if out != "a/b/c.txt[1,93]:a test b [truncated]\na/b/c.txt[2]-context [truncated]\n" {
+		t.Errorf("Invalid output: %s", out)
+	}
+}
+
+func TestWriterSink_HandleResult_Binary(t *testing.T) {
+	var sb strings.Builder
+	sink := NewWriter(&sb)
+
+	sink.HandleResult(base.SearchResult{Path: "a/b/c.bin", LineNumber: 4, IsBinary: true})
+	out := sb.String()
+	if out != "Binary file a/b/c.bin matches\n" {
+		t.Errorf("Invalid output: %s", out)
+	}
+}
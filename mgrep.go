@@ -0,0 +1,281 @@
+package mgrep
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"iter"
+	"log"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/pi-kei/mgrep/internal/base"
+	"github.com/pi-kei/mgrep/internal/filter"
+	"github.com/pi-kei/mgrep/internal/glob"
+	"github.com/pi-kei/mgrep/internal/matcher"
+	"github.com/pi-kei/mgrep/internal/reader"
+	"github.com/pi-kei/mgrep/internal/scanner"
+	"github.com/pi-kei/mgrep/internal/searcher"
+	"github.com/pi-kei/mgrep/internal/sink"
+)
+
+var ErrNoPatterns = errors.New("no patterns to search")
+
+// Reason of a warning for an archive that is larger than MaxArchiveSize
+var ErrArchiveTooLarge = reader.ErrArchiveTooLarge
+
+// Returns built-in file types. Returned map can be changed
+func DefaultFileTypes() FileTypes {
+	return FileTypes(filter.DefaultFileTypes())
+}
+
+// Separates path of an archive from path of its member when Archives is set
+const ArchiveSeparator = reader.ArchiveSeparator
+
+// Same as the default of the mgrep command, so a large archive is not read to memory unless asked
+const defaultMaxArchiveSize = 64 * 1024 * 1024
+
+// Options of a search. Zero value searches every file with no limits.
+// Mirrors options of the mgrep command
+type Options struct {
+	Patterns       []string       // regular expressions to search or literal strings when Fixed is set
+	Fixed          bool           // patterns are literal strings
+	IgnoreCase     bool           // case-insensitive search
+	MultiLine      bool           // matches can span several lines. Each file is read as a whole then
+	Invert         bool           // report lines that do not match
+	Before         int            // number of context lines before a match
+	After          int            // number of context lines after a match
+	MaxSize        int64          // max size of a file in bytes. Zero means no limit
+	MaxLength      int            // max number of runes of a reported line. Zero means no limit
+	MaxDepth       int            // max recursion depth. Zero means no limit
+	Include        *regexp.Regexp // search only files that have matching path
+	Exclude        *regexp.Regexp // skip files that have matching path
+	Globs          []string       // gitignore-style globs of paths relative to root. Globs include files or exclude files and dirs if they start with !
+	IgnoreCaseGlob bool           // match Globs case-insensitively
+	Types          []string       // search only files of these types like go or md. See FileTypes
+	ExcludeTypes   []string       // skip files of these types
+	CustomTypes    FileTypes      // types that are added to built-in ones. Globs are added to a built-in type of the same name
+	BinaryMode     BinaryMode     // how to handle binary files
+	NoIgnore       bool           // do not read ignore files
+	SearchZip      bool           // search inside of compressed files
+	Archives       bool           // search inside of archives as directories
+	Follow         bool           // follow symbolic links when Reader is not set
+	Unsorted       bool           // read directories in batches in the order of the file system when Reader is not set
+	Devices        bool           // read devices, named pipes and sockets instead of skipping them. Reading them may block
+	ArchiveNesting int            // max number of archives a file can be in when Archives is set. Zero means 1
+	MaxArchiveSize int64          // max size in bytes of an archive that has to be read to memory when Archives is set. Zero means 64 MiB, negative means no limit
+	Concurrency    int            // number of goroutines. Zero means serial search
+	Ordered        bool           // keep results in walk order when Concurrency is set. Implied by a JSON sink or context lines
+	Reader         Reader         // reads files and directories. Default is the file system of the OS
+	Matcher        Matcher        // finds matches instead of Patterns when set
+	Filters        []Filter       // skip directories, files and results in addition to options
+	Logger         *log.Logger    // logs errors and warnings. Default discards them
+}
+
+// Returns a reader of the file system of the OS
+func NewFileSystemReader() Reader {
+	return &publicReader{reader.NewFileSystem()}
+}
+
+// Returns a reader of any file system like embed.FS or fstest.MapFS.
+// Paths are slash-separated and unrooted as io/fs requires. Root of a file system is "."
+func NewFSReader(fsys fs.FS) Reader {
+	return &publicReader{reader.NewFS(fsys)}
+}
+
+// Returns a sink that writes results as text the same way mgrep command does
+func NewTextSink(writer io.Writer) Sink {
+	return &publicSink{sink.NewWriter(writer)}
+}
+
+// Returns a sink that writes results as JSON Lines.
+// Sink implements io.Closer, closing it writes a summary message
+func NewJSONSink(writer io.Writer) Sink {
+	return &publicSink{sink.NewJSON(writer)}
+}
+
+// Searches files starting at root and returns results as an iterator with a summary.
+// Summary is filled when the iteration ends. Breaking the loop cancels the search.
+// Errors of files and directories are yielded with an empty result and do not stop the search.
+// Entries that failed to read are yielded the same way as *Warning errors and collected in Summary.Warnings.
+// Invalid options are yielded as the only error
+func Search(ctx context.Context, root string, opts Options) (iter.Seq2[Result, error], *Summary) {
+	searcherIns, searchMatcher, err := opts.build(root, sink.NewNoop())
+	if err != nil {
+		return func(yield func(Result, error) bool) {
+			yield(Result{}, err)
+		}, &Summary{Errors: 1, Err: err}
+	}
+	results, searchSummary := searcherIns.Results(ctx, root, searchMatcher)
+	summary := &Summary{}
+	return func(yield func(Result, error) bool) {
+		// Search has ended when the loop is over, even if it is broken
+		defer func() {
+			*summary = publicSummary(*searchSummary)
+		}()
+		for result, err := range results {
+			if !yield(publicResult(result), publicError(err)) {
+				return
+			}
+		}
+	}, summary
+}
+
+// Searches files starting at root and hands results to a sink.
+// Returns error only if options are invalid
+func Run(ctx context.Context, root string, opts Options, sinkIns Sink) (Summary, error) {
+	searcherIns, searchMatcher, err := opts.build(root, adaptSink(sinkIns))
+	if err != nil {
+		return Summary{}, err
+	}
+	return publicSummary(searcherIns.Search(ctx, root, searchMatcher)), nil
+}
+
+// Builds a searcher and a matcher the same way mgrep command does
+func (o Options) build(root string, sinkIns base.Sink) (base.Searcher, base.Matcher, error) {
+	searchMatcher, err := o.matcher()
+	if err != nil {
+		return nil, nil, err
+	}
+	var globs glob.Set
+	for _, pattern := range o.Globs {
+		if err := globs.Add(pattern, o.IgnoreCaseGlob); err != nil {
+			return nil, nil, err
+		}
+	}
+	var readerIns base.Reader
+	if o.Reader != nil {
+		readerIns = adaptReader(o.Reader)
+	} else {
+		var fileSystemOptions []reader.FileSystemOption
+		if o.Follow {
+			fileSystemOptions = append(fileSystemOptions, reader.WithFollow())
+		}
+		if o.Unsorted {
+			fileSystemOptions = append(fileSystemOptions, reader.WithUnsorted())
+		}
+		readerIns = reader.NewFileSystem(fileSystemOptions...)
+	}
+	if o.Archives {
+		maxArchiveSize := o.MaxArchiveSize
+		if maxArchiveSize == 0 {
+			maxArchiveSize = defaultMaxArchiveSize
+		}
+		readerIns = reader.NewArchive(readerIns, reader.WithArchiveMaxNesting(max(o.ArchiveNesting, 1)), reader.WithArchiveMaxSize(maxArchiveSize))
+	}
+	logger := o.Logger
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+
+	var specialOptions []filter.SpecialOption
+	if o.Devices {
+		specialOptions = append(specialOptions, filter.WithSpecialDevices())
+	}
+	if o.Follow {
+		specialOptions = append(specialOptions, filter.WithSpecialLinks())
+	}
+	filters := []base.Filter{filter.NewSpecial(specialOptions...)}
+	if len(o.Types) > 0 || len(o.ExcludeTypes) > 0 {
+		fileTypes := filter.DefaultFileTypes()
+		for name, typeGlobs := range o.CustomTypes {
+			fileTypes[name] = append(fileTypes[name], typeGlobs...)
+		}
+		types, err := filter.NewType(fileTypes, o.Types, o.ExcludeTypes)
+		if err != nil {
+			return nil, nil, err
+		}
+		filters = append(filters, types)
+	}
+	filters = append(filters, filter.NewConfigurable(
+		func(dirEntry base.DirEntry) bool {
+			if o.MaxDepth > 0 && dirEntry.Depth > o.MaxDepth {
+				return true
+			}
+			return !globs.Empty() && dirEntry.Path != root && !globs.Selected(glob.RelativePath(root, dirEntry.Path), true)
+		},
+		func(fileEntry base.DirEntry) bool {
+			if o.MaxSize > 0 && fileEntry.Size > o.MaxSize {
+				return true
+			}
+			if o.Include != nil && !o.Include.MatchString(fileEntry.Path) {
+				return true
+			}
+			if o.Exclude != nil && o.Exclude.MatchString(fileEntry.Path) {
+				return true
+			}
+			return !globs.Empty() && !globs.Selected(glob.RelativePath(root, fileEntry.Path), false)
+		},
+		func(searchResult base.SearchResult) bool {
+			return false
+		},
+	))
+	if !o.NoIgnore {
+		filters = append(filters, filter.NewIgnore(readerIns, filter.DefaultIgnoreFileNames...))
+	}
+	for _, filterIns := range o.Filters {
+		filters = append(filters, &filterAdapter{filterIns})
+	}
+	filterIns := filter.NewChain(filters...)
+
+	scannerOptions := []scanner.LineOption{scanner.WithContext(o.Before, o.After), scanner.WithBinaryMode(scanner.BinaryMode(o.BinaryMode)), scanner.WithMaxLength(o.MaxLength)}
+	if o.Invert {
+		scannerOptions = append(scannerOptions, scanner.WithInvert())
+	}
+	if o.MultiLine {
+		maxSize := o.MaxSize
+		if maxSize == 0 {
+			// Scanner reads one byte more than that
+			maxSize = math.MaxInt64 - 1
+		}
+		scannerOptions = append(scannerOptions, scanner.WithMultiLine(maxSize))
+	}
+	scannerReader := readerIns
+	if o.SearchZip {
+		scannerReader = reader.NewDecompress(readerIns, reader.WithDecompressMaxSize(o.MaxSize))
+	}
+	scannerIns := scanner.NewLine(scannerReader, scannerOptions...)
+
+	if o.Concurrency <= 0 {
+		return searcher.NewSerial(scannerIns, filterIns, sinkIns, logger), searchMatcher, nil
+	}
+	var concurrentOptions []searcher.ConcurrentOption
+	// JSON messages and context groups of a file are written together, so results of files must not interleave
+	if _, isJSON := sinkIns.(*sink.JSON); o.Ordered || isJSON || o.Before > 0 || o.After > 0 {
+		concurrentOptions = append(concurrentOptions, searcher.WithOrdered())
+	}
+	return searcher.NewConcurrent(scannerIns, filterIns, sinkIns, logger, o.Concurrency, 0, concurrentOptions...), searchMatcher, nil
+}
+
+func (o Options) matcher() (base.Matcher, error) {
+	if o.Matcher != nil {
+		return &matcherAdapter{o.Matcher}, nil
+	}
+	if len(o.Patterns) == 0 {
+		return nil, ErrNoPatterns
+	}
+	if o.Fixed {
+		var literals []string
+		for _, pattern := range o.Patterns {
+			literals = append(literals, strings.Split(pattern, "\n")...)
+		}
+		return matcher.NewFixed(literals, o.IgnoreCase), nil
+	}
+	patterns := make([]string, len(o.Patterns))
+	for i, pattern := range o.Patterns {
+		if o.IgnoreCase {
+			pattern = "(?i)" + pattern
+		}
+		if o.MultiLine {
+			pattern = "(?m)" + pattern
+		}
+		patterns[i] = pattern
+	}
+	searchRegexp, err := matcher.CompileRegexps(patterns)
+	if err != nil {
+		return nil, err
+	}
+	return searchRegexp, nil
+}
@@ -0,0 +1,270 @@
+package mgrep
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/pi-kei/mgrep/internal/scanner"
+)
+
+var testFS = fstest.MapFS{
+	"a.txt":       {Data: []byte("match\nno\nmatch match")},
+	"dir/b.txt":   {Data: []byte("no\nmatch")},
+	"dir/c.log":   {Data: []byte("match")},
+	"dir/.ignore": {Data: []byte("*.log\n")},
+	"dir/pipe":    {Data: []byte("match"), Mode: fs.ModeNamedPipe},
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"Serial", Options{Patterns: []string{"match"}}, []string{"a.txt:1", "a.txt:3", "dir/b.txt:2"}},
+		{"Ordered", Options{Patterns: []string{"match"}, Concurrency: 4, Ordered: true}, []string{"a.txt:1", "a.txt:3", "dir/b.txt:2"}},
+		{"No ignore", Options{Patterns: []string{"match"}, NoIgnore: true}, []string{"a.txt:1", "a.txt:3", "dir/b.txt:2", "dir/c.log:1"}},
+		{"Max depth", Options{Patterns: []string{"match"}, MaxDepth: 1}, []string{"a.txt:1", "a.txt:3", "dir/b.txt:2"}},
+		{"Include", Options{Patterns: []string{"MATCH"}, IgnoreCase: true, Include: regexp.MustCompile(`^dir/`)}, []string{"dir/b.txt:2"}},
+		{"Fixed", Options{Patterns: []string{"no"}, Fixed: true}, []string{"a.txt:2", "dir/b.txt:1"}},
+		{"Filters", Options{Patterns: []string{"match"}, Filters: []Filter{skipPathFilter("a.txt")}}, []string{"dir/b.txt:2"}},
+		{"Types", Options{Patterns: []string{"match"}, NoIgnore: true, Types: []string{"log"}, CustomTypes: FileTypes{"log": {"*.log"}}}, []string{"dir/c.log:1"}},
+		{"Exclude types", Options{Patterns: []string{"match"}, NoIgnore: true, ExcludeTypes: []string{"txt"}, CustomTypes: FileTypes{"txt": {"*.txt"}}}, []string{"dir/c.log:1"}},
+		{"Globs", Options{Patterns: []string{"match"}, NoIgnore: true, Globs: []string{"*.log", "*.TXT", "!/a.txt"}, IgnoreCaseGlob: true}, []string{"dir/b.txt:2", "dir/c.log:1"}},
+		{"Exclude dir glob", Options{Patterns: []string{"match"}, Globs: []string{"!dir/"}}, []string{"a.txt:1", "a.txt:3"}},
+		{"Devices", Options{Patterns: []string{"match"}, Devices: true, Include: regexp.MustCompile(`^dir/`)}, []string{"dir/b.txt:2", "dir/pipe:1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Reader = NewFSReader(testFS)
+			results, summary := Search(context.Background(), ".", tt.opts)
+			var got []string
+			for result, err := range results {
+				if err != nil {
+					t.Fatalf("Search error: %v", err)
+				}
+				got = append(got, result.Path+":"+strconv.Itoa(result.LineNumber))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Search() = %v, want %v", got, tt.want)
+			}
+			if summary.Matches != len(tt.want) || summary.Errors != 0 {
+				t.Errorf("Summary = %v", *summary)
+			}
+		})
+	}
+}
+
+func TestSearch_Break(t *testing.T) {
+	for _, concurrency := range []int{0, 4} {
+		results, summary := Search(context.Background(), ".", Options{Patterns: []string{"match"}, Reader: NewFSReader(testFS), Concurrency: concurrency})
+		count := 0
+		for range results {
+			count++
+			break
+		}
+		if count != 1 || summary.Matches < 1 {
+			t.Errorf("Concurrency %v: got %v results, summary %v", concurrency, count, *summary)
+		}
+	}
+}
+
+func TestSearch_Errors(t *testing.T) {
+	results, summary := Search(context.Background(), ".", Options{Patterns: []string{"("}, Reader: NewFSReader(testFS)})
+	for _, err := range results {
+		if err == nil {
+			t.Errorf("Search yielded a result for invalid pattern")
+		}
+	}
+	if summary.Err == nil {
+		t.Errorf("Summary has no error")
+	}
+
+	results, summary = Search(context.Background(), "missing", Options{Patterns: []string{"match"}, Reader: NewFSReader(testFS)})
+	var errs []error
+	for _, err := range results {
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || errs[0] == nil || summary.Errors != 1 {
+		t.Errorf("Search yielded %v, summary %v", errs, *summary)
+	}
+
+	if _, err := Run(context.Background(), ".", Options{Patterns: []string{"match"}, Types: []string{"unknown"}}, NewTextSink(nil)); err == nil {
+		t.Errorf("Run returned no error for unknown type")
+	}
+
+	if _, err := Run(context.Background(), ".", Options{Patterns: []string{"match"}, Globs: []string{""}}, NewTextSink(nil)); err == nil {
+		t.Errorf("Run returned no error for empty glob")
+	}
+
+	if _, err := Run(context.Background(), ".", Options{}, NewTextSink(nil)); !errors.Is(err, ErrNoPatterns) {
+		t.Errorf("Run error = %v, want %v", err, ErrNoPatterns)
+	}
+}
+
+func TestRun(t *testing.T) {
+	var sb strings.Builder
+	summary, err := Run(context.Background(), "dir", Options{Patterns: []string{"match"}, Reader: NewFSReader(testFS)}, NewJSONSink(&sb))
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if summary.Matches != 1 || !strings.Contains(sb.String(), `"path":{"text":"dir/b.txt"}`) {
+		t.Errorf("Run() = %v, output %q", summary, sb.String())
+	}
+}
+
+func TestRun_Adapters(t *testing.T) {
+	readErr := errors.New("test")
+	var sink collectSink
+	opts := Options{
+		Matcher:    wordMatcher("match"),
+		Reader:     failingDirReader{NewFSReader(testFS), "dir", readErr},
+		BinaryMode: BinarySkip,
+	}
+	summary, err := Run(context.Background(), ".", opts, &sink)
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if strings.Join(sink.results, ",") != "a.txt:1:0-5,a.txt:3:0-5,a.txt:3:6-11" || strings.Join(sink.files, ",") != "a.txt" {
+		t.Errorf("Run handled results %v, files %v", sink.results, sink.files)
+	}
+	if summary.Matches != 2 || summary.Errors != 0 || len(summary.Warnings) != 1 || summary.Warnings[0].Path != "dir" || !errors.Is(summary.Warnings[0], readErr) {
+		t.Errorf("Summary = %v", summary)
+	}
+
+	results, searchSummary := Search(context.Background(), ".", opts)
+	var warnings []*Warning
+	for _, err := range results {
+		var warning *Warning
+		if errors.As(err, &warning) {
+			warnings = append(warnings, warning)
+		}
+	}
+	if len(warnings) != 1 || len(searchSummary.Warnings) != 1 || searchSummary.Matches != 2 {
+		t.Errorf("Search yielded warnings %v, summary %v", warnings, *searchSummary)
+	}
+
+	if scanner.BinaryMode(BinaryMatches) != scanner.BinaryMatches || scanner.BinaryMode(BinarySkip) != scanner.BinarySkip || scanner.BinaryMode(BinaryText) != scanner.BinaryText {
+		t.Errorf("Binary modes differ from the scanner")
+	}
+}
+
+func TestSearch_MaxArchiveSize(t *testing.T) {
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	writer := tar.NewWriter(gzipWriter)
+	content := strings.Repeat("match\n", 1000)
+	if err := writer.WriteHeader(&tar.Header{Name: "a.txt", Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	writer.Write([]byte(content))
+	writer.Close()
+	gzipWriter.Close()
+	archiveFS := fstest.MapFS{"a.tar.gz": {Data: buffer.Bytes()}}
+
+	tests := []struct {
+		name        string
+		maxSize     int64
+		wantMatches int
+	}{
+		{"Default", 0, 1000},
+		{"Too large", int64(buffer.Len() - 1), 0},
+		{"No limit", -1, 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sink collectSink
+			summary, err := Run(context.Background(), ".", Options{Patterns: []string{"match"}, Archives: true, MaxArchiveSize: tt.maxSize, Reader: NewFSReader(archiveFS)}, &sink)
+			if err != nil {
+				t.Fatalf("Run error: %v", err)
+			}
+			if summary.Matches != tt.wantMatches {
+				t.Errorf("Summary = %v", summary)
+			}
+			if tt.wantMatches == 0 && (len(summary.Warnings) != 1 || !errors.Is(summary.Warnings[0], ErrArchiveTooLarge)) {
+				t.Errorf("Warnings = %v, want %v", summary.Warnings, ErrArchiveTooLarge)
+			}
+		})
+	}
+}
+
+// Finds a literal word
+type wordMatcher string
+
+func (w wordMatcher) FindAll(b []byte, n int) []Match {
+	var matches []Match
+	for offset := 0; n < 0 || len(matches) < n; {
+		i := strings.Index(string(b[offset:]), string(w))
+		if i < 0 {
+			break
+		}
+		matches = append(matches, Match{StartIndex: offset + i, EndIndex: offset + i + len(w)})
+		offset += i + len(w)
+	}
+	return matches
+}
+
+func (w wordMatcher) MultiLine() bool {
+	return false
+}
+
+func (w wordMatcher) Literals() []string {
+	return []string{string(w)}
+}
+
+// Reader that fails to read a specified directory
+type failingDirReader struct {
+	Reader
+	path string
+	err  error
+}
+
+func (r failingDirReader) ReadDir(dirEntry DirEntry) (DirIterator, error) {
+	if dirEntry.Path == r.path {
+		return nil, r.err
+	}
+	return r.Reader.ReadDir(dirEntry)
+}
+
+// Collects handled results and ended files that have matches
+type collectSink struct {
+	results []string
+	files   []string
+}
+
+func (c *collectSink) HandleResult(result Result) {
+	for _, match := range result.Matches {
+		c.results = append(c.results, fmt.Sprintf("%s:%d:%d-%d", result.Path, result.LineNumber, match.StartIndex, match.EndIndex))
+	}
+}
+
+func (c *collectSink) HandleFileEnd(path string, matches int) {
+	if matches > 0 {
+		c.files = append(c.files, path)
+	}
+}
+
+// Skips files that have a specified path
+type skipPathFilter string
+
+func (s skipPathFilter) SkipDirEntry(dirEntry DirEntry) bool {
+	return false
+}
+
+func (s skipPathFilter) SkipFileEntry(fileEntry DirEntry) bool {
+	return fileEntry.Path == string(s)
+}
+
+func (s skipPathFilter) SkipSearchResult(searchResult Result) bool {
+	return false
+}
@@ -0,0 +1,131 @@
+package mgrep
+
+import (
+	"io"
+	"io/fs"
+	"time"
+)
+
+// File or directory that is read by a Reader
+type DirEntry struct {
+	Path    string      // path to entry
+	Depth   int         // recursion depth
+	IsDir   bool        // whether the entry describes a directory
+	Size    int64       // size of a file in bytes
+	ModTime time.Time   // modification time
+	Mode    fs.FileMode // type bits like fs.ModeNamedPipe or fs.ModeSymlink. Zero for regular files and directories
+}
+
+// Single match in a line
+type Match struct {
+	StartIndex int // start index of a match 0-based
+	EndIndex   int // end index (exclusive) of a match 0-based
+	Pattern    int // index of a pattern that matched 0-based when several patterns are searched
+}
+
+// Line that has matches, a context line or a binary file that matches.
+// Several lines when a match spans them
+type Result struct {
+	Path          string  // path to file
+	LineNumber    int     // line number 1-based
+	EndLineNumber int     // number of the last line 1-based when the result spans several lines, zero otherwise
+	Line          string  // full line that has a match. Lines are separated by new lines when the result spans several of them
+	Matches       []Match // all non-overlapping matches in a line in order of appearance
+	IsContext     bool    // whether the line is a context line around a match. It has no matches then
+	IsBinary      bool    // whether the file is binary and has a match. It has no line and matches then
+	Truncated     bool    // whether the line is too long and Line has only a part of it around the first match
+	LineOffset    int     // byte offset of Line in the full line when it is truncated
+	ColumnOffset  int     // rune offset of Line in the full line when it is truncated
+}
+
+// File or directory that failed to read and was skipped while its siblings were still searched
+type Warning struct {
+	Path string // path to entry
+	Err  error  // reason the entry was skipped
+}
+
+func (w *Warning) Error() string {
+	return w.Path + ": " + w.Err.Error()
+}
+
+func (w *Warning) Unwrap() error {
+	return w.Err
+}
+
+// Outcome of a search
+type Summary struct {
+	Matches  int        // number of handled results that have matches, context lines are not counted
+	Errors   int        // number of errors occured while reading dirs and files
+	Err      error      // first occured error or nil
+	Warnings []*Warning // entries that were skipped because they failed to read. Not counted as errors
+}
+
+// Iterator of entries of a directory
+type DirIterator interface {
+	// Goes to next entry.
+	// Returns false when there are no more entries or error occured that stops the iteration.
+	// Returns true when the next entry failed to read but the rest can be read. Err() returns its error then
+	Next() bool
+	// Returns current entry. Only a path is set if the entry failed to read
+	Value() DirEntry
+	// Returns error of current entry after Next() returned true
+	// or error that stopped the iteration after Next() returned false
+	Err() error
+}
+
+// Reads files and directories
+type Reader interface {
+	// Opens a file to read its content
+	OpenFile(fileEntry DirEntry) (io.ReadCloser, error)
+	// Reads child entries of a directory.
+	// Iterator can return valid entries even if error is not nil.
+	// Iterator that holds resources implements io.Closer and is closed when it is not read to the end
+	ReadDir(dirEntry DirEntry) (DirIterator, error)
+	// Reads root entry of a search. Entry can be a file or a directory
+	ReadRootEntry(name string, depth int) (DirEntry, error)
+}
+
+// Skips directories, files and results
+type Filter interface {
+	// Checks if a directory is skipped. Its entries are not read then
+	SkipDirEntry(dirEntry DirEntry) bool
+	// Checks if a file is skipped. Its content is not read then
+	SkipFileEntry(fileEntry DirEntry) bool
+	// Checks if a result is skipped
+	SkipSearchResult(result Result) bool
+}
+
+// Handles results
+type Sink interface {
+	// Handles a result
+	HandleResult(result Result)
+	// Handles the end of a searched file. Called after all results of the file are handled.
+	// Matches is a number of handled results of the file that have matches.
+	// Not called for files that failed to read or were skipped as binary or when search is cancelled
+	HandleFileEnd(path string, matches int)
+}
+
+// Finds matches in a text
+type Matcher interface {
+	// Finds successive non-overlapping matches in b.
+	// Returns at most n matches or all of them if n < 0, nil if there are no matches
+	FindAll(b []byte, n int) []Match
+	// Checks if a match can contain a new line character and span several lines
+	MultiLine() bool
+	// Returns literal strings that every match contains.
+	// Text that misses any of them is skipped without running the matcher.
+	// Returns nil if there are no such literals
+	Literals() []string
+}
+
+// How to handle binary files
+type BinaryMode int
+
+const (
+	BinaryMatches BinaryMode = iota // report only the first match without a line
+	BinarySkip                      // do not scan binary files
+	BinaryText                      // scan binary files as text
+)
+
+// Globs of file types by type name. Globs are matched against base names of files
+type FileTypes map[string][]string